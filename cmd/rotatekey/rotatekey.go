@@ -0,0 +1,55 @@
+package rotatekey
+
+import (
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/secret"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"c"},
+			Usage:   "Load configuration from `FILE`",
+		},
+		&cli.StringFlag{
+			Name:  "new-key",
+			Usage: "Use the given base64 or hex encoded key instead of generating one",
+		},
+	}
+
+	Command = &cli.Command{
+		Name:   "rotate-key",
+		Usage:  "Re-encrypt stored secrets with a new master key",
+		Flags:  flags,
+		Action: action,
+	}
+)
+
+func action(c *cli.Context) error {
+	conf, err := app.InitConfig(c.String("config"), false)
+	if err != nil {
+		return err
+	}
+	if err := app.InitSecret(conf); err != nil {
+		return err
+	}
+	if _, err := app.InitSettings(conf, false); err != nil {
+		return err
+	}
+	if err := app.InitDb(conf); err != nil {
+		return err
+	}
+
+	var newKey []byte
+	if v := c.String("new-key"); v != "" {
+		if newKey, err = secret.ParseKey(v); err != nil {
+			return err
+		}
+	} else if newKey, err = secret.GenerateKey(); err != nil {
+		return err
+	}
+
+	return app.RotateMasterKey(conf, newKey)
+}
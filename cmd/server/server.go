@@ -1,8 +1,17 @@
 package server
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/bitxeno/atvloadly/internal/task"
 	"github.com/bitxeno/atvloadly/web"
 	"github.com/fatih/color"
@@ -53,6 +62,9 @@ func action(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := app.InitSecret(conf); err != nil {
+		return err
+	}
 	setttings, err := app.InitSettings(conf, debug)
 	if err != nil {
 		return err
@@ -78,6 +90,9 @@ func action(c *cli.Context) error {
 	if err := app.InitDb(conf); err != nil {
 		return err
 	}
+	if err := service.EnsureAdminUser(); err != nil {
+		return err
+	}
 
 	// start jobs
 	_ = task.ScheduleRefreshApps()
@@ -88,7 +103,48 @@ func action(c *cli.Context) error {
 	if c.Int("port") > 0 {
 		port = c.Int("port")
 	}
-	return web.Run(conf.Server.ListenAddr, port)
+	chErr := make(chan error, 1)
+	go func() {
+		chErr <- web.Run(conf.Server.ListenAddr, port)
+	}()
+
+	chSignal := make(chan os.Signal, 1)
+	signal.Notify(chSignal, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-chErr:
+		return err
+	case sig := <-chSignal:
+		log.Infof("Received signal %s, shutting down...", sig)
+	}
+	signal.Stop(chSignal)
+
+	shutdown(time.Duration(conf.Server.ShutdownTimeout) * time.Second)
+	return nil
+}
+
+// shutdown stops the server, giving running installs the grace period to
+// finish before they are interrupted.
+func shutdown(grace time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := web.Shutdown(ctx); err != nil {
+		log.Err(err).Msg("Failed to shutdown web server")
+	}
+	manager.StopDeviceManager()
+	task.Shutdown(ctx)
+
+	wsCtx, wsCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer wsCancel()
+	web.CloseWebsockets(wsCtx)
+
+	if err := app.FlushSettings(); err != nil {
+		log.Err(err).Msg("Failed to save settings")
+	}
+	if err := db.Close(); err != nil {
+		log.Err(err).Msg("Failed to close database")
+	}
+	log.Info("Server stopped.")
 }
 
 func printVersion() {
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"path/filepath"
 
 	"github.com/bitxeno/atvloadly/internal/cfg"
@@ -58,8 +59,17 @@ func InitSettings(conf *Configuration, debug bool) (*SettingsConfiguration, erro
 	if err := c.BindStruct(&settings); err != nil {
 		return nil, err
 	}
+	plaintext, err := settings.decryptSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("decrypt settings failed: %w", err)
+	}
 	go startSaveSettingsJob(path)
 	Settings = &settings
+	settingsPath = path
+	if plaintext {
+		// migrate secrets written before encryption was enabled
+		SaveSettings()
+	}
 
 	if debug {
 		c.PrintConfig()
@@ -93,7 +103,18 @@ func InitDb(conf *Configuration) error {
 	if conf.Db.Path == "" {
 		conf.Db.Path = cfg.DefaultConfigDir()
 	}
-	if err := db.Open(conf.Db).AutoMigrate(&model.InstalledApp{}); err != nil {
+	if err := db.Open(conf.Db).AutoMigrate(
+		&model.InstalledApp{},
+		&model.User{},
+		&model.UserSession{},
+		&model.APIToken{},
+		&model.AuditLog{},
+		&model.Job{},
+		&model.RefreshAttempt{},
+		&model.ExpiryAlert{},
+		&model.AppIDRegistration{},
+		&model.IpaVersion{},
+	); err != nil {
 		return err
 	}
 
@@ -34,11 +34,55 @@ type Configuration struct {
 		ListenAddr string `koanf:"listen_addr" default:"0.0.0.0"`
 		Port       int    `koanf:"port" default:"9000"`
 		DataDir    string `koanf:"work_dir"`
+		// seconds to wait for running installs on shutdown before interrupting them
+		ShutdownTimeout int `koanf:"shutdown_timeout" default:"60"`
+
+		Auth struct {
+			Enabled       bool   `koanf:"enabled" json:"enabled" default:"false"`
+			SessionTTL    int    `koanf:"session_ttl" json:"session_ttl" default:"168"`
+			AdminUsername string `koanf:"admin_username" json:"admin_username" default:"admin"`
+			AdminPassword string `koanf:"admin_password" json:"-"`
+		} `koanf:"auth" json:"auth"`
+
+		TLS struct {
+			Enabled bool `koanf:"enabled" json:"enabled" default:"false"`
+			// leave empty to use a self-signed certificate issued by a local CA in <work_dir>/tls
+			CertFile string   `koanf:"cert_file" json:"cert_file"`
+			KeyFile  string   `koanf:"key_file" json:"key_file"`
+			Hosts    []string `koanf:"hosts" json:"hosts"`
+			// plain HTTP port redirecting to HTTPS, 0 disables it
+			RedirectHTTPPort int `koanf:"redirect_http_port" json:"redirect_http_port" default:"0"`
+		} `koanf:"tls" json:"tls"`
+
+		Terminal struct {
+			// full, restricted or disabled
+			Mode string `koanf:"mode" json:"mode" default:"restricted"`
+			// plumesign subcommands allowed in restricted mode, each followed by
+			// the flags it may be given, empty uses tty.DefaultAllowlist
+			Allowlist []string `koanf:"allowlist" json:"allowlist"`
+			Record    bool     `koanf:"record" json:"record" default:"true"`
+		} `koanf:"terminal" json:"terminal"`
 	} `koanf:"server" json:"server"`
 
+	Security struct {
+		MasterKeyFile string `koanf:"master_key_file" json:"master_key_file"`
+	} `koanf:"security" json:"security"`
+
 	Db db.Config `koanf:"db" json:"db"`
 }
 
+func TLSCertDir(conf *Configuration) string {
+	return filepath.Join(conf.Server.DataDir, "tls")
+}
+
+func RecordingDir(conf *Configuration) string {
+	return filepath.Join(conf.Server.DataDir, "recordings")
+}
+
+func AttemptLogDir(conf *Configuration) string {
+	return filepath.Join(conf.Server.DataDir, "log", "attempts")
+}
+
 func SideloadDataDir() string {
 	if home, err := os.UserHomeDir(); err != nil {
 		return "~/.config/PlumeImpactor"
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/secret"
+)
+
+const (
+	masterKeyEnv         = "ATVLOADLY_MASTER_KEY"
+	previousMasterKeyEnv = "ATVLOADLY_MASTER_KEY_PREVIOUS"
+)
+
+// MasterKeyPath returns the key file used when the key is not given
+// through the ATVLOADLY_MASTER_KEY environment variable.
+func MasterKeyPath(conf *Configuration) string {
+	if conf.Security.MasterKeyFile != "" {
+		return conf.Security.MasterKeyFile
+	}
+	return filepath.Join(conf.Server.DataDir, "master.key")
+}
+
+// InitSecret loads the master key used to encrypt credentials at rest.
+// The key comes from ATVLOADLY_MASTER_KEY, or from the key file, which is
+// generated on first start. A "<key file>.bak" left by rotate-key is kept as
+// a decrypt-only key so an interrupted rotation never loses data.
+func InitSecret(conf *Configuration) error {
+	key, older, err := loadMasterKeys(conf)
+	if err != nil {
+		return fmt.Errorf("load master key failed: %w", err)
+	}
+	return secret.SetKeys(key, older...)
+}
+
+func loadMasterKeys(conf *Configuration) ([]byte, [][]byte, error) {
+	if v := os.Getenv(masterKeyEnv); v != "" {
+		key, err := secret.ParseKey(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		var older [][]byte
+		if prev := os.Getenv(previousMasterKeyEnv); prev != "" {
+			prevKey, err := secret.ParseKey(prev)
+			if err != nil {
+				return nil, nil, err
+			}
+			older = append(older, prevKey)
+		}
+		return key, older, nil
+	}
+
+	path := MasterKeyPath(conf)
+	key, err := secret.ReadKeyFile(path, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var older [][]byte
+	if backup, err := secret.ReadKeyFile(path+".bak", false); err == nil {
+		older = append(older, backup)
+	}
+	return key, older, nil
+}
+
+// RotateMasterKey re-encrypts every stored secret with newKey and replaces
+// the key file. The previous key file is kept as "<key file>.bak".
+// InitSecret, InitSettings and InitDb must have been called before.
+func RotateMasterKey(conf *Configuration, newKey []byte) error {
+	oldKey, older, err := loadMasterKeys(conf)
+	if err != nil {
+		return err
+	}
+	if err := secret.SetKeys(newKey, append(older, oldKey)...); err != nil {
+		return err
+	}
+
+	fromEnv := os.Getenv(masterKeyEnv) != ""
+	path := MasterKeyPath(conf)
+	if !fromEnv {
+		// persist the new key before any value is encrypted with it
+		if err := secret.WriteKeyFile(path+".new", newKey); err != nil {
+			return err
+		}
+		if err := os.Rename(path, path+".bak"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(path+".new", path); err != nil {
+			return err
+		}
+	}
+
+	var apps []model.InstalledApp
+	if result := db.Store().Find(&apps); result.Error != nil {
+		return result.Error
+	}
+	for _, v := range apps {
+		if v.Password == "" {
+			continue
+		}
+		if result := db.Store().Model(&v).Update("password", v.Password); result.Error != nil {
+			return result.Error
+		}
+	}
+	log.Infof("Re-encrypted %d installed app records.", len(apps))
+
+	if err := FlushSettings(); err != nil {
+		return err
+	}
+
+	if fromEnv {
+		log.ColorWarnf("Set %s=%s before restarting the server.\n", masterKeyEnv, secret.EncodeKey(newKey))
+	} else {
+		log.Infof("Master key rotated (%s). The previous key is kept in %s.bak until you delete it.", secret.KeyID(newKey), path)
+	}
+	return nil
+}
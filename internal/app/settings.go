@@ -6,11 +6,13 @@ import (
 	"time"
 
 	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/secret"
 	"github.com/bitxeno/atvloadly/internal/utils"
 )
 
 var (
-	Settings *SettingsConfiguration
+	Settings     *SettingsConfiguration
+	settingsPath string
 )
 var saveTimer *time.Timer = time.NewTimer(math.MaxInt64)
 
@@ -21,21 +23,40 @@ const (
 
 type TaskMode string
 
+const (
+	NotifyModeFailures NotifyMode = "failures"
+	NotifyModeBatch    NotifyMode = "batch"
+	NotifyModeDigest   NotifyMode = "digest"
+)
+
+// NotifyMode controls when refresh results are notified: failed batches
+// only, every finished batch, or a daily digest.
+type NotifyMode string
+
 type SettingsConfiguration struct {
 	App struct {
 		Language string `koanf:"language" json:"language"`
 	} `koanf:"app" json:"app"`
 	Task struct {
-		Enabled       bool     `koanf:"enabled" json:"enabled" default:"true"`
-		IphoneEnabled bool     `koanf:"iphone_enabled" json:"iphone_enabled" default:"true"`
-		Mode          TaskMode `koanf:"mode" json:"mode" default:"1"`
-		CrodTime      string   `koanf:"crod_time" json:"crod_time" default:"0,30 3-6 * * *"`
-		AdvanceDays   int      `koanf:"advance_days" json:"advance_days" default:"1"`
+		Enabled         bool     `koanf:"enabled" json:"enabled" default:"true"`
+		IphoneEnabled   bool     `koanf:"iphone_enabled" json:"iphone_enabled" default:"true"`
+		Mode            TaskMode `koanf:"mode" json:"mode" default:"1"`
+		CrodTime        string   `koanf:"crod_time" json:"crod_time" default:"0,30 3-6 * * *"`
+		AdvanceDays     int      `koanf:"advance_days" json:"advance_days" default:"1"`
+		MaxConcurrency  int      `koanf:"max_concurrency" json:"max_concurrency" default:"2"`
+		AccountInterval int      `koanf:"account_interval" json:"account_interval" default:"30"`
+		PaidAccounts    string   `koanf:"paid_accounts" json:"paid_accounts"`
+		IpaVersions     int      `koanf:"ipa_versions" json:"ipa_versions" default:"3"`
 	} `koanf:"task" json:"task"`
 	Notification struct {
-		Enabled  bool   `koanf:"enabled" json:"enabled"`
-		Type     string `koanf:"type" json:"type" default:"weixin"`
-		Telegram struct {
+		Enabled          bool       `koanf:"enabled" json:"enabled"`
+		Type             string     `koanf:"type" json:"type" default:"weixin"`
+		Mode             NotifyMode `koanf:"mode" json:"mode" default:"failures"`
+		DigestTime       string     `koanf:"digest_time" json:"digest_time" default:"09:00"`
+		StaleDeviceDays  int        `koanf:"stale_device_days" json:"stale_device_days" default:"3"`
+		ExpiryAlertHours string     `koanf:"expiry_alert_hours" json:"expiry_alert_hours" default:"48,24,6"`
+		BaseURL          string     `koanf:"base_url" json:"base_url"`
+		Telegram         struct {
 			BotToken string `koanf:"bot_token" json:"bot_token"`
 			ChatID   string `koanf:"chat_id" json:"chat_id"`
 		} `koanf:"telegram" json:"telegram"`
@@ -72,10 +93,77 @@ type SettingsConfiguration struct {
 	} `koanf:"network" json:"network"`
 }
 
+// secretFields lists the settings that are stored encrypted in settings.json.
+func (s *SettingsConfiguration) secretFields() []*string {
+	return []*string{
+		&s.Notification.Telegram.BotToken,
+		&s.Notification.Weixin.CorpSecret,
+		&s.Notification.Bark.DeviceKey,
+		&s.Notification.Email.Password,
+		&s.Notification.Webhook.Header,
+	}
+}
+
+// decryptSecrets decrypts the secret fields in place and reports whether any
+// of them was still stored in plaintext.
+func (s *SettingsConfiguration) decryptSecrets() (bool, error) {
+	plaintext := false
+	for _, field := range s.secretFields() {
+		if *field == "" {
+			continue
+		}
+		if !secret.IsEncrypted(*field) {
+			plaintext = true
+			continue
+		}
+		value, err := secret.Decrypt(*field)
+		if err != nil {
+			return false, err
+		}
+		*field = value
+	}
+	return plaintext, nil
+}
+
+func (s *SettingsConfiguration) encryptSecrets() error {
+	for _, field := range s.secretFields() {
+		value, err := secret.Encrypt(*field)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+	return nil
+}
+
 func SaveSettings() {
 	saveTimer.Reset(100 * time.Millisecond)
 }
 
+// FlushSettings writes the settings to disk immediately, replacing a pending
+// SaveSettings.
+func FlushSettings() error {
+	if settingsPath == "" {
+		return nil
+	}
+	saveTimer.Stop()
+	return writeSettings(settingsPath)
+}
+
+func writeSettings(path string) error {
+	settings := *Settings
+	if err := settings.encryptSecrets(); err != nil {
+		return err
+	}
+
+	data := utils.ToIndentJSON(settings)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	// tighten files created by older versions with 0777
+	return os.Chmod(path, 0600)
+}
+
 func startSaveSettingsJob(settingsPath string) {
 	go func() {
 		for {
@@ -87,8 +175,7 @@ func startSaveSettingsJob(settingsPath string) {
 				continue
 			}
 
-			data := utils.ToIndentJSON(Settings)
-			if err := os.WriteFile(settingsPath, data, os.ModePerm); err != nil {
+			if err := writeSettings(settingsPath); err != nil {
 				log.Err(err).Msg("Save settings error.")
 			} else {
 				log.Infof("Save settings success. %s", settingsPath)
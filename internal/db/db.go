@@ -79,6 +79,18 @@ func Store() *gorm.DB {
 	return instance.db
 }
 
+// Close closes the database, Store must not be used afterwards.
+func Close() error {
+	if instance == nil || instance.db == nil {
+		return nil
+	}
+	sqlDB, err := instance.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 func Open(conf Config) *sqliteDb {
 	instance = new(conf).Open()
 
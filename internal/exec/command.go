@@ -13,7 +13,14 @@ import (
 	"github.com/bitxeno/atvloadly/internal/log"
 )
 
-var ErrCommandTimeout = errors.New("command execute timeout")
+var (
+	ErrCommandTimeout  = errors.New("command execute timeout")
+	ErrCommandCanceled = errors.New("command canceled")
+)
+
+// Delay before the output pipes are closed after the process is killed,
+// grandchildren may still hold them open.
+const waitDelay = 5 * time.Second
 
 // Cmd represents a Cmd to be executed.
 type Cmd struct {
@@ -100,10 +107,13 @@ func (c *Cmd) Run() error {
 
 	err := cmd.Run()
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			_ = cmd.Process.Kill()
 			return fmt.Errorf("%s %w", err.Error(), ErrCommandTimeout)
 		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return fmt.Errorf("%s %w", err.Error(), ErrCommandCanceled)
+		}
 		return c.parseError(err, nil)
 	}
 	return nil
@@ -141,6 +151,8 @@ func (c *Cmd) setupCmd(cmd *exec.Cmd) {
 	cmd.Stdout = c.Stdout
 	cmd.Stderr = c.Stderr
 	cmd.Stdin = c.Stdin
+	cmd.WaitDelay = waitDelay
+	setProcessTree(cmd)
 }
 
 func (c *Cmd) parseError(err error, output []byte) error {
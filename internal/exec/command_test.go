@@ -1,6 +1,8 @@
 package exec
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -35,3 +37,18 @@ func TestCommand_CombinedOutput(t *testing.T) {
 		t.Errorf("Expected error to contain 'something went wrong', got '%v'", err)
 	}
 }
+
+func TestCommand_CancelKillsProcessTree(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	// the shell spawns a child that would outlive a plain kill of the shell
+	start := time.Now()
+	err := CommandContext(ctx, "sh", "-c", "sleep 30; echo done").Run()
+	if !errors.Is(err, ErrCommandCanceled) {
+		t.Fatalf("Expected ErrCommandCanceled, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("Cancel should stop the command right away, took %s", time.Since(start))
+	}
+}
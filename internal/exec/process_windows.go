@@ -0,0 +1,16 @@
+//go:build windows
+
+package exec
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessTree makes cancelling the command also kill the processes it
+// spawned.
+func setProcessTree(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
@@ -0,0 +1,201 @@
+package ipa
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitxeno/atvloadly/internal/utils"
+	plist "howett.net/plist"
+)
+
+var (
+	ErrInvalidBundleIdentifier = errors.New("bundle identifier may only contain letters, digits, '-' and '.', e.g. com.example.app")
+	ErrInvalidVersion          = errors.New("version must be up to three period-separated integers, e.g. 1.2.3")
+)
+
+var (
+	// Payload/UnicornApp.app/Info.plist
+	regMainInfoPlist = regexp.MustCompile(`^Payload/[^/]+\.app/Info\.plist$`)
+	// Payload/UnicornApp.app/PlugIns/Widget.appex/Info.plist
+	regNestedInfoPlist = regexp.MustCompile(`^Payload/[^/]+\.app/.+\.(app|appex)/Info\.plist$`)
+
+	regBundleIdentifier = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+	regShortVersion     = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+)
+
+// Overrides replaces the bundle identifier, display name and version of an
+// IPA, empty fields keep the values of the IPA. A display name localized in
+// InfoPlist.strings still takes precedence on devices using that language.
+type Overrides struct {
+	BundleIdentifier string
+	DisplayName      string
+	Version          string
+}
+
+func (o Overrides) IsEmpty() bool {
+	return o.BundleIdentifier == "" && o.DisplayName == "" && o.Version == ""
+}
+
+func (o Overrides) Validate() error {
+	if o.BundleIdentifier != "" && !regBundleIdentifier.MatchString(o.BundleIdentifier) {
+		return ErrInvalidBundleIdentifier
+	}
+	if o.Version != "" && !regShortVersion.MatchString(o.Version) {
+		return ErrInvalidVersion
+	}
+	return nil
+}
+
+// ApplyOverrides writes a copy of the IPA at path with the Info.plist of the
+// app rewritten by o, and returns the path of the copy in the temp dir. The
+// bundle identifiers of app extensions are moved under the new identifier, as
+// the signer requires them to be prefixed by the one of the app. The caller is
+// responsible for removing the copy.
+func ApplyOverrides(path string, o Overrides) (string, error) {
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var mainPlist *zip.File
+	for _, f := range r.File {
+		if regMainInfoPlist.MatchString(f.Name) {
+			mainPlist = f
+			break
+		}
+	}
+	if mainPlist == nil {
+		return "", ErrInfoPlistNotFound
+	}
+	oldIdentifier, err := readBundleIdentifier(mainPlist)
+	if err != nil {
+		return "", err
+	}
+
+	name := sanitizeName(utils.FileNameWithoutExt(filepath.Base(path)))
+	out, err := os.CreateTemp(os.TempDir(), name+"_override_*.ipa")
+	if err != nil {
+		return "", err
+	}
+	outPath := out.Name()
+	if err := rewriteIPA(out, r, mainPlist, oldIdentifier, o); err != nil {
+		_ = out.Close()
+		_ = os.Remove(outPath)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(outPath)
+		return "", err
+	}
+	return outPath, nil
+}
+
+func rewriteIPA(out io.Writer, r *zip.ReadCloser, mainPlist *zip.File, oldIdentifier string, o Overrides) error {
+	w := zip.NewWriter(out)
+	for _, f := range r.File {
+		var edit func(info map[string]any)
+		if f == mainPlist {
+			edit = func(info map[string]any) { overrideMain(info, o) }
+		} else if o.BundleIdentifier != "" && regNestedInfoPlist.MatchString(f.Name) {
+			edit = func(info map[string]any) { overrideNested(info, oldIdentifier, o.BundleIdentifier) }
+		}
+
+		if edit == nil {
+			// unchanged files are copied without recompressing
+			if err := w.Copy(f); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := rewritePlist(w, f, edit); err != nil {
+			return fmt.Errorf("failed to rewrite %s: %w", f.Name, err)
+		}
+	}
+	return w.Close()
+}
+
+func overrideMain(info map[string]any, o Overrides) {
+	if o.BundleIdentifier != "" {
+		info["CFBundleIdentifier"] = o.BundleIdentifier
+	}
+	if o.DisplayName != "" {
+		info["CFBundleDisplayName"] = o.DisplayName
+	}
+	if o.Version != "" {
+		info["CFBundleShortVersionString"] = o.Version
+	}
+}
+
+func overrideNested(info map[string]any, oldIdentifier, newIdentifier string) {
+	id, _ := info["CFBundleIdentifier"].(string)
+	if oldIdentifier != "" && strings.HasPrefix(id, oldIdentifier+".") {
+		info["CFBundleIdentifier"] = newIdentifier + strings.TrimPrefix(id, oldIdentifier)
+	}
+}
+
+// rewritePlist writes f to w with its plist edited, keeping the plist format.
+func rewritePlist(w *zip.Writer, f *zip.File, edit func(info map[string]any)) error {
+	data, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	info := map[string]any{}
+	format, err := plist.Unmarshal(data, &info)
+	if err != nil {
+		return err
+	}
+	edit(info)
+	if data, err = plist.Marshal(info, format); err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:     f.Name,
+		Method:   f.Method,
+		Modified: f.Modified,
+	}
+	header.SetMode(f.Mode())
+	fw, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+func readBundleIdentifier(f *zip.File) (string, error) {
+	data, err := readZipFile(f)
+	if err != nil {
+		return "", err
+	}
+	info := map[string]any{}
+	if _, err := plist.Unmarshal(data, &info); err != nil {
+		return "", err
+	}
+	id, _ := info["CFBundleIdentifier"].(string)
+	return id, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,140 @@
+package ipa
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	plist "howett.net/plist"
+)
+
+// writeTestIPA writes an IPA with an app extension to dir.
+func writeTestIPA(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "fake.ipa")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	plists := map[string]map[string]any{
+		"Payload/Fake.app/Info.plist": {
+			"CFBundleIdentifier":         "com.example.fake",
+			"CFBundleName":               "Fake",
+			"CFBundleShortVersionString": "1.0",
+			"CFBundleVersion":            "100",
+		},
+		"Payload/Fake.app/PlugIns/TopShelf.appex/Info.plist": {
+			"CFBundleIdentifier": "com.example.fake.topshelf",
+		},
+	}
+	w := zip.NewWriter(f)
+	for name, info := range plists {
+		data, err := plist.Marshal(info, plist.BinaryFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = fw.Write(data)
+	}
+	fw, _ := w.Create("Payload/Fake.app/Fake")
+	_, _ = fw.Write([]byte("binary"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readTestPlist(t *testing.T, path, name string) (map[string]any, int) {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := map[string]any{}
+		format, err := plist.Unmarshal(data, &info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info, format
+	}
+	t.Fatalf("%s not found in %s", name, path)
+	return nil, 0
+}
+
+func TestApplyOverrides(t *testing.T) {
+	src := writeTestIPA(t, t.TempDir())
+
+	out, err := ApplyOverrides(src, Overrides{
+		BundleIdentifier: "com.example.fake.nightly",
+		DisplayName:      "Fake Nightly",
+		Version:          "2.0.1",
+	})
+	if err != nil {
+		t.Fatalf("ApplyOverrides returned error: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(out)
+	}()
+
+	parsed, err := ParseFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Identifier() != "com.example.fake.nightly" || parsed.Name() != "Fake Nightly" || parsed.Version() != "2.0.1" {
+		t.Fatalf("parsed = %s %s %s, want overridden values", parsed.Identifier(), parsed.Name(), parsed.Version())
+	}
+	if parsed.Build() != "100" {
+		t.Fatalf("build = %s, want 100 unchanged", parsed.Build())
+	}
+
+	info, format := readTestPlist(t, out, "Payload/Fake.app/PlugIns/TopShelf.appex/Info.plist")
+	if info["CFBundleIdentifier"] != "com.example.fake.nightly.topshelf" {
+		t.Fatalf("extension identifier = %v, want it under the new identifier", info["CFBundleIdentifier"])
+	}
+	if format != plist.BinaryFormat {
+		t.Fatalf("plist format = %d, want binary kept", format)
+	}
+
+	orig, _ := ParseFile(src)
+	if orig.Identifier() != "com.example.fake" {
+		t.Fatalf("source ipa was modified: %s", orig.Identifier())
+	}
+}
+
+func TestOverridesValidate(t *testing.T) {
+	tests := []struct {
+		overrides Overrides
+		err       error
+	}{
+		{Overrides{}, nil},
+		{Overrides{BundleIdentifier: "com.example.app-2", Version: "1.2.3"}, nil},
+		{Overrides{BundleIdentifier: "example"}, ErrInvalidBundleIdentifier},
+		{Overrides{BundleIdentifier: "com.example.app_2"}, ErrInvalidBundleIdentifier},
+		{Overrides{Version: "1.2.3.4"}, ErrInvalidVersion},
+		{Overrides{Version: "nightly"}, ErrInvalidVersion},
+	}
+	for _, tt := range tests {
+		if err := tt.overrides.Validate(); err != tt.err {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.overrides, err, tt.err)
+		}
+	}
+}
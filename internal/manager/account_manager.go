@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/bitxeno/atvloadly/internal/app"
-	"github.com/bitxeno/atvloadly/internal/exec"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/model"
 )
@@ -48,10 +47,7 @@ func (am *AccountManager) GetAccounts() (*model.Accounts, error) {
 }
 
 func (am *AccountManager) LogoutAccount(email string) error {
-	_, err := exec.NewCommand("plumesign", "account", "logout", "-u", email).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	_, err := signer.Logout(email)
 	if err != nil {
 		log.Err(err).Msgf("Error logout account: %s", email)
 		return err
@@ -60,10 +56,7 @@ func (am *AccountManager) LogoutAccount(email string) error {
 }
 
 func (am *AccountManager) GetAccountDevices(email string) ([]model.AccountDevice, error) {
-	output, err := exec.NewCommand("plumesign", "account", "devices", "-u", email).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	output, err := signer.AccountDevices(email)
 	if err != nil {
 		log.Err(err).Msgf("Error getting devices for %s", email)
 		return nil, err
@@ -112,10 +105,7 @@ func (am *AccountManager) GetAccountDevices(email string) ([]model.AccountDevice
 }
 
 func (am *AccountManager) DeleteAccountDevice(email, deviceID string) error {
-	_, err := exec.NewCommand("plumesign", "account", "delete-device", "-u", email, "--device-id", deviceID).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	_, err := signer.DeleteAccountDevice(email, deviceID)
 	if err != nil {
 		log.Err(err).Msgf("Error deleting device %s for %s", deviceID, email)
 		return err
@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// Stdio connects a long running backend command to its manager, e.g. an
+// install waiting for a 2FA code on stdin.
+type Stdio struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Signer signs and installs apps with an Apple ID and manages the account,
+// its certificates and registered devices. Methods returning []byte return
+// the combined output for the managers to parse.
+type Signer interface {
+	Install(ctx context.Context, opts InstallOptions, provisionPath string, timeout time.Duration, stdio Stdio) error
+	Login(ctx context.Context, account, password string, timeout time.Duration, stdio Stdio) error
+	Logout(account string) ([]byte, error)
+	AccountDevices(account string) ([]byte, error)
+	DeleteAccountDevice(account, deviceID string) ([]byte, error)
+	Certificates(account string) ([]byte, error)
+	RevokeCertificate(account, serialNumber string) ([]byte, error)
+	ExportCertificate(account, password, path string) ([]byte, error)
+	ImportCertificate(account, password, path string) ([]byte, error)
+}
+
+// DeviceBackend talks to the devices over usbmuxd or a remote pairing tunnel.
+type DeviceBackend interface {
+	DeviceInfo(dev *model.Device) ([]byte, error)
+	CheckAfc(dev *model.Device) ([]byte, error)
+	FindPairing(identifier, authTag string) ([]byte, error)
+	Pair(ctx context.Context, dev model.Device, timeout time.Duration, stdio Stdio) error
+	CheckPairing(ip, port, path string) ([]byte, error)
+	Mount(ctx context.Context, dev *model.Device, imagePath, manifestPath, trustCachePath string) ([]byte, error)
+	Screenshot(ctx context.Context, dev *model.Device, outputPath string) ([]byte, error)
+}
+
+var (
+	signer        Signer        = plumesign{}
+	deviceBackend DeviceBackend = plumesign{}
+)
+
+// SetBackend replaces the signer and device backend, nil restores plumesign.
+// It is not safe to call while commands are running, set it at startup or in
+// tests.
+func SetBackend(s Signer, d DeviceBackend) {
+	if s == nil {
+		s = plumesign{}
+	}
+	if d == nil {
+		d = plumesign{}
+	}
+	signer, deviceBackend = s, d
+}
@@ -4,8 +4,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/bitxeno/atvloadly/internal/app"
-	"github.com/bitxeno/atvloadly/internal/exec"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/model"
 )
@@ -19,10 +17,7 @@ func newCertificateManager() *CertificateManager {
 }
 
 func (m *CertificateManager) GetCertificates(email string) ([]model.Certificate, error) {
-	output, err := exec.NewCommand("plumesign", "certificate", "list", "-u", email).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	output, err := signer.Certificates(email)
 	if err != nil {
 		log.Err(err).Msgf("Error getting certificates for %s", email)
 		return nil, err
@@ -52,10 +47,7 @@ func (m *CertificateManager) GetCertificates(email string) ([]model.Certificate,
 }
 
 func (m *CertificateManager) RevokeCertificate(email string, serialNumber string) error {
-	_, err := exec.NewCommand("plumesign", "certificate", "revoke", "-u", email, "-s", serialNumber).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	_, err := signer.RevokeCertificate(email, serialNumber)
 	if err != nil {
 		log.Err(err).Msgf("Error revoking certificate %s", serialNumber)
 		return err
@@ -64,10 +56,7 @@ func (m *CertificateManager) RevokeCertificate(email string, serialNumber string
 }
 
 func (m *CertificateManager) ExportCertificate(email, password, path string) (string, error) {
-	output, err := exec.NewCommand("plumesign", "certificate", "export", "-u", email, "-p", password, "-o", path).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	output, err := signer.ExportCertificate(email, password, path)
 	if err != nil {
 		log.Err(err).Msgf("Error exporting certificate for %s", email)
 		return string(output), err
@@ -76,10 +65,7 @@ func (m *CertificateManager) ExportCertificate(email, password, path string) (st
 }
 
 func (m *CertificateManager) ImportCertificate(email, password, path string) error {
-	output, err := exec.NewCommand("plumesign", "certificate", "import", "-u", email, "-p", password, "-i", path).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	output, err := signer.ImportCertificate(email, password, path)
 	if err != nil {
 		log.Err(err).Msgf("Error importing certificate for %s: %s", email, string(output))
 		return err
@@ -9,10 +9,8 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/bitxeno/atvloadly/internal/app"
-	"github.com/bitxeno/atvloadly/internal/exec"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/model"
 	"github.com/bitxeno/atvloadly/internal/utils"
@@ -97,12 +95,7 @@ func (dm *DeviceManager) GetDeviceByUDID(udid string) (*model.Device, bool) {
 }
 
 func (dm *DeviceManager) GetDeviceInfo(dev *model.Device) (*model.DeviceInfo, error) {
-	cmd := exec.Command("plumesign", "device-info", "-u", dev.UDID).WithTimeout(5 * time.Second)
-	if dev.Connection == model.DeviceConnectionRemote {
-		cmd = exec.Command("plumesign", "device-info", "--ip", dev.IP, "--port", fmt.Sprintf("%d", dev.Port), "-u", dev.UDID).WithTimeout(5 * time.Second)
-	}
-
-	data, err := cmd.CombinedOutput()
+	data, err := deviceBackend.DeviceInfo(dev)
 	if err != nil {
 		log.Err(err).Msgf("Error getting device info for %s (%s): %s", dev.Name, dev.UDID, string(data))
 		return nil, fmt.Errorf("%s%s", string(data), err.Error())
@@ -245,12 +238,7 @@ func (dm *DeviceManager) ReloadDevices() {
 }
 
 func (dm *DeviceManager) CheckAfcServiceStatus(dev *model.Device) error {
-	cmd := exec.Command("plumesign", "check", "afc", "--udid", dev.UDID).WithTimeout(10 * time.Second)
-	if dev.Connection == model.DeviceConnectionRemote {
-		cmd = exec.Command("plumesign", "check", "afc", "--ip", dev.IP, "--port", fmt.Sprintf("%d", dev.Port), "--udid", dev.UDID).WithTimeout(10 * time.Second)
-	}
-
-	data, err := cmd.CombinedOutput()
+	data, err := deviceBackend.CheckAfc(dev)
 	if err != nil {
 		return err
 	}
@@ -272,9 +260,7 @@ func (dm *DeviceManager) CheckDevicePaired(identifier string, authTag string) (*
 		return nil, nil
 	}
 
-	cmd := exec.Command("plumesign", "check", "find-pairing", "--identifier", identifier, "--auth-tag", authTag).WithTimeout(10 * time.Second)
-
-	data, err := cmd.CombinedOutput()
+	data, err := deviceBackend.FindPairing(identifier, authTag)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// failureKeywords maps plumesign output and errors to failure codes, matched
+// in order against the lower-cased text as whole words, so "2fa" does not match
+// inside a UUID. More specific causes come first, a pairing error is also a
+// lockdown error for example.
+var failureKeywords = []struct {
+	code     model.FailureCode
+	keywords []string
+}{
+	{model.FailureTwoFactorRequired, []string{"2fa", "two-factor", "two factor", "verification code"}},
+	{model.FailureAccountInvalid, []string{"plumesign account list", "can't log-in", "developersession creation failed", "incorrect password", "invalid username or password"}},
+	{model.FailureAnisette, []string{"anisette"}},
+	{model.FailureMaxCertificates, []string{"maximum number of certificates", "too many certificates", "already have a current"}},
+	{model.FailureCertificateRevoked, []string{"certificate has been revoked", "certificate revoked", "certificate is revoked"}},
+	{model.FailureAppIDLimit, []string{"maximum number of app ids", "app id limit", "cannot create more than 10 app ids"}},
+	{model.FailureDeviceDiskFull, []string{"no space left", "not enough space", "insufficient storage", "amdnospaceerror", "kamdnospaceerror"}},
+	{model.FailurePairingInvalid, []string{"invalidhostid", "invalid_host_id", "invalid host id", "pairing_dialog", "not paired", "pairing file"}},
+	{model.FailureAfcError, []string{"afc_e_", "mux_error", "afc service not available", "usbmuxd"}},
+	{model.FailureDeviceOffline, []string{"device not found", "no device found", "device_not_found", "lockdown_e_"}},
+	{model.FailureTimeout, []string{"timeout", "timed out"}},
+	{model.FailureInvalidIPA, []string{"failed to parse ipa", "invalid ipa", "info.plist not found"}},
+}
+
+// failurePatterns are the compiled failureKeywords.
+var failurePatterns = compileFailureKeywords()
+
+func compileFailureKeywords() map[model.FailureCode][]*regexp.Regexp {
+	patterns := make(map[model.FailureCode][]*regexp.Regexp, len(failureKeywords))
+	for _, v := range failureKeywords {
+		for _, keyword := range v.keywords {
+			patterns[v.code] = append(patterns[v.code], keywordPattern(keyword))
+		}
+	}
+	return patterns
+}
+
+// keywordPattern matches keyword not surrounded by letters or digits. "_" is
+// a separator, LOCKDOWN_E_MUX_ERROR matches "mux_error". Keywords ending in a
+// separator, like "afc_e_", match as a prefix.
+func keywordPattern(keyword string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(keyword)
+	if isAlnum(keyword[0]) {
+		pattern = `(?:^|[^a-z0-9])` + pattern
+	}
+	if isAlnum(keyword[len(keyword)-1]) {
+		pattern += `(?:[^a-z0-9]|$)`
+	}
+	return regexp.MustCompile(pattern)
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// FailureError is an install error classified from the plumesign output.
+type FailureError struct {
+	Code model.FailureCode
+	Err  error
+}
+
+func (e *FailureError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FailureError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyOutput returns the failure code matching text, or an empty code
+// when the cause is unknown.
+func ClassifyOutput(text string) model.FailureCode {
+	lower := strings.ToLower(text)
+	for _, v := range failureKeywords {
+		if matchesFailure(lower, v.code) {
+			return v.code
+		}
+	}
+	return ""
+}
+
+// HasFailureKeyword reports whether text contains any keyword of code,
+// whichever class ClassifyOutput picks for it.
+func HasFailureKeyword(text string, code model.FailureCode) bool {
+	return matchesFailure(strings.ToLower(text), code)
+}
+
+func matchesFailure(lower string, code model.FailureCode) bool {
+	for _, re := range failurePatterns[code] {
+		if re.MatchString(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// FailureCodeOf returns the failure code of an install error.
+func FailureCodeOf(err error) model.FailureCode {
+	if err == nil {
+		return ""
+	}
+	var failureErr *FailureError
+	if errors.As(err, &failureErr) {
+		return failureErr.Code
+	}
+	if errors.Is(err, ErrAccountInvalid) {
+		return model.FailureAccountInvalid
+	}
+	if code := ClassifyOutput(err.Error()); code != "" {
+		return code
+	}
+	return model.FailureUnknown
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	cases := map[string]model.FailureCode{
+		"Error: Can't log-in, run plumesign account list": model.FailureAccountInvalid,
+		"Enter 2FA code:":                                                       model.FailureTwoFactorRequired,
+		"Error: failed to fetch anisette data":                                  model.FailureAnisette,
+		"Error: You have reached the maximum number of certificates":            model.FailureMaxCertificates,
+		"Error: certificate has been revoked":                                   model.FailureCertificateRevoked,
+		"Error: cannot create more than 10 App IDs in 7 days":                   model.FailureAppIDLimit,
+		"Error: kAMDNoSpaceError, no space left on device":                      model.FailureDeviceDiskFull,
+		"Error: LOCKDOWN_E_INVALID_HOST_ID":                                     model.FailurePairingInvalid,
+		"Error: AFC_E_MUX_ERROR":                                                model.FailureAfcError,
+		"Error: LOCKDOWN_E_MUX_ERROR":                                           model.FailureAfcError,
+		"Error: device not found":                                               model.FailureDeviceOffline,
+		"Error: operation timed out":                                            model.FailureTimeout,
+		"Error: something else":                                                 "",
+		"Profile 5E2FA1C4-8B1D-4F2A-9E3B-2FA07C1D9E42 saved":                    "",
+		"Error: device not found, profile 1B2FA3E4-0000-1111-2222-333344445555": model.FailureDeviceOffline,
+		"Error: AMDNoSpaceError":                                                model.FailureDeviceDiskFull,
+	}
+	for text, want := range cases {
+		if got := ClassifyOutput(text); got != want {
+			t.Errorf("ClassifyOutput(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestHasFailureKeyword(t *testing.T) {
+	// a 2FA prompt before a failed login is still an invalid account
+	text := "Enter 2FA code:\nError: Can't log-in: invalid username or password"
+	if got := ClassifyOutput(text); got != model.FailureTwoFactorRequired {
+		t.Fatalf("ClassifyOutput = %s, want %s", got, model.FailureTwoFactorRequired)
+	}
+	if !HasFailureKeyword(text, model.FailureAccountInvalid) {
+		t.Fatalf("HasFailureKeyword(%q, account_invalid) = false, want true", text)
+	}
+	if HasFailureKeyword("Error: AFC_E_MUX_ERROR", model.FailureAccountInvalid) {
+		t.Fatalf("HasFailureKeyword(afc error, account_invalid) = true, want false")
+	}
+}
+
+func TestFailureCodeOf(t *testing.T) {
+	err := fmt.Errorf("refresh failed: %w", &FailureError{Code: model.FailureDeviceDiskFull, Err: errors.New("exit status 1")})
+	if got := FailureCodeOf(err); got != model.FailureDeviceDiskFull {
+		t.Fatalf("FailureCodeOf = %s, want %s", got, model.FailureDeviceDiskFull)
+	}
+	if got := FailureCodeOf(errors.New("exit status 1")); got != model.FailureUnknown {
+		t.Fatalf("FailureCodeOf = %s, want %s", got, model.FailureUnknown)
+	}
+	if got := FailureCodeOf(nil); got != "" {
+		t.Fatalf("FailureCodeOf(nil) = %s, want empty", got)
+	}
+}
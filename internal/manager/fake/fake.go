@@ -0,0 +1,306 @@
+// Package fake provides an in-process Signer and DeviceBackend that plays
+// scripted plumesign runs, so the install pipeline can be tested on a machine
+// without Apple hardware.
+package fake
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	execx "github.com/bitxeno/atvloadly/internal/exec"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// errExit is returned like the exit status of a failed plumesign run.
+var errExit = errors.New("exit status 1")
+
+// Script describes how the Apple side answers a login or install.
+type Script struct {
+	// TwoFactorCode makes the login prompt for this 2FA code and wait for it
+	// on stdin.
+	TwoFactorCode string
+	// CodeTimeout is how long to wait for the 2FA code, 0 waits until the run
+	// is cancelled.
+	CodeTimeout time.Duration
+	// LoginError fails the login with this error.
+	LoginError string
+	// InstallError fails the install after signing with this error.
+	InstallError string
+	// Delay is the time spent in each install phase.
+	Delay time.Duration
+}
+
+// Success logs in and installs right away.
+func Success() Script {
+	return Script{}
+}
+
+// TwoFactor asks for code before logging in, and gives up after timeout.
+func TwoFactor(code string, timeout time.Duration) Script {
+	return Script{TwoFactorCode: code, CodeTimeout: timeout}
+}
+
+// AccountError fails the login like an invalid Apple ID.
+func AccountError() Script {
+	return Script{LoginError: "Can't log-in: invalid username or password"}
+}
+
+// Slow installs successfully, spending delay in each phase.
+func Slow(delay time.Duration) Script {
+	return Script{Delay: delay}
+}
+
+var (
+	_ manager.Signer        = (*Backend)(nil)
+	_ manager.DeviceBackend = (*Backend)(nil)
+)
+
+// Backend plays the scripts queued per account. Devices are always reachable.
+type Backend struct {
+	mu       sync.Mutex
+	scripts  map[string][]Script
+	installs []manager.InstallOptions
+	logins   []string
+}
+
+func New() *Backend {
+	return &Backend{scripts: make(map[string][]Script)}
+}
+
+// Script queues scripts for the next runs with account. The last script is
+// kept for all later runs, accounts without scripts succeed.
+func (b *Backend) Script(account string, scripts ...Script) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scripts[account] = append(b.scripts[account], scripts...)
+}
+
+// Installs returns the options of every install run so far.
+func (b *Backend) Installs() []manager.InstallOptions {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]manager.InstallOptions(nil), b.installs...)
+}
+
+// Logins returns the account of every login run so far.
+func (b *Backend) Logins() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.logins...)
+}
+
+func (b *Backend) next(account string) Script {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	scripts := b.scripts[account]
+	if len(scripts) == 0 {
+		return Success()
+	}
+	s := scripts[0]
+	if len(scripts) > 1 {
+		b.scripts[account] = scripts[1:]
+	}
+	return s
+}
+
+func (b *Backend) Install(ctx context.Context, opts manager.InstallOptions, provisionPath string, timeout time.Duration, stdio manager.Stdio) error {
+	b.mu.Lock()
+	b.installs = append(b.installs, opts)
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	r := &run{ctx: ctx, script: b.next(opts.Account), stdio: stdio}
+	if err := r.login(opts.Account); err != nil {
+		return err
+	}
+
+	steps := []string{
+		fmt.Sprintf("Registering device %s", opts.UDID),
+		"Creating App ID",
+		"Fetching provisioning profile",
+		"Signing bundle",
+		"Uploading 50%",
+		"Uploading 100%",
+		"Installing 50%",
+		"Installing 100%",
+	}
+	for _, step := range steps {
+		if err := r.wait(); err != nil {
+			return err
+		}
+		r.println(step)
+	}
+	if r.script.InstallError != "" {
+		return r.fail(r.script.InstallError)
+	}
+	r.println("Installation Succeeded")
+	return nil
+}
+
+func (b *Backend) Login(ctx context.Context, account, password string, timeout time.Duration, stdio manager.Stdio) error {
+	b.mu.Lock()
+	b.logins = append(b.logins, account)
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	r := &run{ctx: ctx, script: b.next(account), stdio: stdio}
+	return r.login(account)
+}
+
+func (b *Backend) Logout(account string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) AccountDevices(account string) ([]byte, error) {
+	return []byte("[]"), nil
+}
+
+func (b *Backend) DeleteAccountDevice(account, deviceID string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) Certificates(account string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) RevokeCertificate(account, serialNumber string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) ExportCertificate(account, password, path string) ([]byte, error) {
+	return nil, errors.New("certificate export is not supported by the fake backend")
+}
+
+func (b *Backend) ImportCertificate(account, password, path string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) DeviceInfo(dev *model.Device) ([]byte, error) {
+	return fmt.Appendf(nil, "UniqueDeviceID: %s\nDeviceName: %s\nDeviceClass: %s\n", dev.UDID, dev.Name, dev.DeviceClass), nil
+}
+
+func (b *Backend) CheckAfc(dev *model.Device) ([]byte, error) {
+	return []byte("SUCCESS"), nil
+}
+
+func (b *Backend) FindPairing(identifier, authTag string) ([]byte, error) {
+	return nil, errors.New("pairing is not supported by the fake backend")
+}
+
+func (b *Backend) Pair(ctx context.Context, dev model.Device, timeout time.Duration, stdio manager.Stdio) error {
+	return errors.New("pairing is not supported by the fake backend")
+}
+
+func (b *Backend) CheckPairing(ip, port, path string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) Mount(ctx context.Context, dev *model.Device, imagePath, manifestPath, trustCachePath string) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *Backend) Screenshot(ctx context.Context, dev *model.Device, outputPath string) ([]byte, error) {
+	return nil, errors.New("screenshot is not supported by the fake backend")
+}
+
+// run plays one script to the stdio of a command.
+type run struct {
+	ctx    context.Context
+	script Script
+	stdio  manager.Stdio
+}
+
+func (r *run) login(account string) error {
+	r.println(fmt.Sprintf("Logging in as %s", account))
+	if r.script.TwoFactorCode != "" {
+		r.print("Enter 2FA code: ")
+		code, err := r.readCode()
+		if err != nil {
+			return err
+		}
+		if code != r.script.TwoFactorCode {
+			return r.fail("Incorrect verification code")
+		}
+	}
+	if r.script.LoginError != "" {
+		return r.fail(r.script.LoginError)
+	}
+	r.println("Successfully logged in")
+	return nil
+}
+
+// readCode waits for a line on stdin.
+func (r *run) readCode() (string, error) {
+	if r.stdio.Stdin == nil {
+		return "", r.fail("2FA code required but stdin is closed")
+	}
+	ch := make(chan string, 1)
+	go func() {
+		// unblocks when the manager closes the pipe
+		line, _ := bufio.NewReader(r.stdio.Stdin).ReadString('\n')
+		ch <- strings.TrimSpace(line)
+	}()
+
+	var timeout <-chan time.Time
+	if r.script.CodeTimeout > 0 {
+		timer := time.NewTimer(r.script.CodeTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case code := <-ch:
+		return code, nil
+	case <-timeout:
+		return "", r.fail("2FA code was not entered in time")
+	case <-r.ctx.Done():
+		return "", r.stopped()
+	}
+}
+
+// wait spends the phase delay of the script.
+func (r *run) wait() error {
+	if r.script.Delay <= 0 {
+		if r.ctx.Err() != nil {
+			return r.stopped()
+		}
+		return nil
+	}
+	select {
+	case <-time.After(r.script.Delay):
+		return nil
+	case <-r.ctx.Done():
+		return r.stopped()
+	}
+}
+
+// stopped returns the error of a killed command, like execx.
+func (r *run) stopped() error {
+	if errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("signal: killed %w", execx.ErrCommandTimeout)
+	}
+	return fmt.Errorf("signal: killed %w", execx.ErrCommandCanceled)
+}
+
+func (r *run) fail(msg string) error {
+	r.println("Error: " + msg)
+	return errExit
+}
+
+func (r *run) print(s string) {
+	if r.stdio.Stdout != nil {
+		_, _ = io.WriteString(r.stdio.Stdout, s)
+	}
+}
+
+func (r *run) println(s string) {
+	r.print(s + "\n")
+}
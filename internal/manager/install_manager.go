@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,10 +10,13 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitxeno/atvloadly/internal/app"
 	execx "github.com/bitxeno/atvloadly/internal/exec"
+	"github.com/bitxeno/atvloadly/internal/ipa"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/model"
 	"github.com/bitxeno/atvloadly/internal/utils"
@@ -21,6 +25,18 @@ import (
 
 var ErrAccountInvalid = errors.New("account invalid")
 
+// activeInstalls counts running plumesign installs, installs for different
+// devices may run in parallel.
+var activeInstalls atomic.Int32
+
+// tempMu guards starting installs against removing temp files, and
+// pendingTemp holds the IPA names whose temp files wait for the running
+// installs to finish, see CleanTempFiles.
+var (
+	tempMu      sync.Mutex
+	pendingTemp = make(map[string]bool)
+)
+
 type InstallManager struct {
 	quietMode bool
 
@@ -41,6 +57,16 @@ type InstallOptions struct {
 	IpaPath          string
 	RemoveExtensions bool
 	RefreshMode      bool
+	Overrides        ipa.Overrides
+}
+
+// AppOverrides returns the Info.plist overrides saved for v.
+func AppOverrides(v model.InstalledApp) ipa.Overrides {
+	return ipa.Overrides{
+		BundleIdentifier: v.OverrideBundleID,
+		DisplayName:      v.OverrideName,
+		Version:          v.OverrideVersion,
+	}
 }
 
 func NewInstallManager() *InstallManager {
@@ -59,16 +85,48 @@ func NewInteractiveInstallManager() *InstallManager {
 	return ins
 }
 
+// TryStart runs the install, retrying once after restarting usbmuxd for
+// device connection errors. Errors are returned as *FailureError.
 func (t *InstallManager) TryStart(ctx context.Context, opts InstallOptions) error {
+	err := t.tryStart(ctx, opts)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	return &FailureError{Code: t.ClassifyFailure(err), Err: err}
+}
+
+// ClassifyFailure looks for the cause in the error lines of the output
+// first, then in err and the whole output.
+func (t *InstallManager) ClassifyFailure(err error) model.FailureCode {
+	if errors.Is(err, ErrAccountInvalid) {
+		return model.FailureAccountInvalid
+	}
+	for _, text := range []string{t.ErrorLog(), err.Error(), t.OutputLog()} {
+		if code := ClassifyOutput(text); code != "" {
+			return code
+		}
+	}
+	return model.FailureUnknown
+}
+
+func (t *InstallManager) tryStart(ctx context.Context, opts InstallOptions) error {
 	err := t.Start(ctx, opts)
 	if err != nil {
 		if t.IsAccountInvalid() {
 			return fmt.Errorf("%s %s %w", t.ErrorLog(), err.Error(), ErrAccountInvalid)
 		}
+		if ctx.Err() != nil {
+			return err
+		}
 
 		// AppleTV system has reboot/lockdownd sleep, try restart usbmuxd to fix
 		// LOCKDOWN_E_MUX_ERROR / AFC_E_MUX_ERROR /
 		ipaName := filepath.Base(opts.IpaPath)
+		if activeInstalls.Load() > 0 {
+			// restarting usbmuxd would break the installs on other devices
+			log.Infof("Skip restarting usbmuxd while other installs are running. %s", ipaName)
+			return err
+		}
 		log.Infof("Try restarting usbmuxd to fix afc connect issue. %s", ipaName)
 		if errmux := usbmuxdManager.Restart(); errmux == nil {
 			// iPhone reconnect may take a while, wait some time
@@ -81,6 +139,16 @@ func (t *InstallManager) TryStart(ctx context.Context, opts InstallOptions) erro
 }
 
 func (t *InstallManager) Start(ctx context.Context, opts InstallOptions) error {
+	tempMu.Lock()
+	activeInstalls.Add(1)
+	tempMu.Unlock()
+	defer func() {
+		tempMu.Lock()
+		defer tempMu.Unlock()
+		if activeInstalls.Add(-1) == 0 {
+			removePendingTempFiles()
+		}
+	}()
 	t.outputStdout.Reset()
 
 	// Large tvOS apps can take longer than 30 minutes to sign and install.
@@ -96,16 +164,20 @@ func (t *InstallManager) Start(ctx context.Context, opts InstallOptions) error {
 	}()
 
 	if err := CheckAfcServiceStatus(opts.UDID); err != nil {
+		t.outputStdout.fail()
 		return fmt.Errorf("afc service not available: %w", err)
 	}
 
-	args := buildInstallArgs(opts, provisionPath)
-	if opts.RemoveExtensions {
-		args = append(args, "--remove-extensions")
-	}
-	if opts.RefreshMode {
-		args = append(args, "--refresh")
+	if !opts.Overrides.IsEmpty() {
+		ipaPath, err := ipa.ApplyOverrides(opts.IpaPath, opts.Overrides)
+		if err != nil {
+			t.outputStdout.fail()
+			return fmt.Errorf("failed to apply Info.plist overrides: %w", err)
+		}
+		defer func() { _ = os.Remove(ipaPath) }()
+		opts.IpaPath = ipaPath
 	}
+
 	stdinReader, stdinWriter, err := os.Pipe()
 	if err != nil {
 		log.Err(err).Msg("Error creating stdin pipe: ")
@@ -118,18 +190,9 @@ func (t *InstallManager) Start(ctx context.Context, opts InstallOptions) error {
 		t.stdin = nil
 	}()
 
-	cmd := execx.CommandContext(ctx, "plumesign", args...).
-		WithTimeout(timeout).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		WithStdout(t.outputStdout).
-		WithStderr(t.outputStdout).
-		WithStdin(stdinReader)
-
-	log.Debugf("Install Command: %s", strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
-
-	err = cmd.Run()
+	err = signer.Install(ctx, opts, provisionPath, timeout, Stdio{Stdin: stdinReader, Stdout: t.outputStdout, Stderr: t.outputStdout})
 	if err != nil {
+		t.outputStdout.fail()
 		if errors.Is(err, execx.ErrCommandTimeout) {
 			log.Err(err).Msgf("Installation exceeded %d-minute timeout limit. %s", int(timeout.Minutes()), t.ErrorLog())
 			return fmt.Errorf("installation exceeded %d-minute timeout limit: %w", int(timeout.Minutes()), err)
@@ -144,24 +207,35 @@ func (t *InstallManager) Start(ctx context.Context, opts InstallOptions) error {
 	return nil
 }
 
-func buildInstallArgs(opts InstallOptions, provisionPath string) []string {
-	if opts.IP != "" && opts.Port != 0 && opts.UDID != "" {
-		return []string{"sign-rsd", "--apple-id", "--register-and-install", "--output-provision", provisionPath, "--ip", opts.IP, "--port", fmt.Sprintf("%d", opts.Port), "--udid", opts.UDID, "-u", opts.Account, "-p", opts.IpaPath}
-	}
-
-	return []string{"sign", "--apple-id", "--register-and-install", "--output-provision", provisionPath, "--udid", opts.UDID, "-u", opts.Account, "-p", opts.IpaPath}
-}
-
 func (t *InstallManager) GetMobileProvisionPath() string {
 	return path.Join(os.TempDir(), fmt.Sprintf("embedded.mobileprovision.%d", time.Now().UnixNano()))
 }
 
+// CleanTempFiles removes the temp files of the install of ipaPath. The
+// patterns may match files of installs still running on other devices, so
+// while any install runs the removal waits until the last one finishes.
 func (t *InstallManager) CleanTempFiles(ipaPath string) {
 	ipaName := filepath.Base(ipaPath)
 	fileNameWithoutExt := strings.TrimSuffix(ipaName, filepath.Ext(ipaName))
 
-	utils.RemoveAllFiles(filepath.Join(app.Config.Server.DataDir, "tmp"), fileNameWithoutExt+"*")
-	utils.RemoveAllFiles(os.TempDir(), fileNameWithoutExt+"*")
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	pendingTemp[fileNameWithoutExt] = true
+	if activeInstalls.Load() == 0 {
+		removePendingTempFiles()
+	}
+}
+
+// removePendingTempFiles must be called with tempMu held and no install running.
+func removePendingTempFiles() {
+	if len(pendingTemp) == 0 {
+		return
+	}
+	for name := range pendingTemp {
+		utils.RemoveAllFiles(filepath.Join(app.Config.Server.DataDir, "tmp"), name+"*")
+		utils.RemoveAllFiles(os.TempDir(), name+"*")
+	}
+	clear(pendingTemp)
 
 	utils.RemoveAllFiles(os.TempDir(), "plume_stage*")
 }
@@ -183,6 +257,24 @@ func (t *InstallManager) OnOutput(fn func(string)) {
 	}))
 }
 
+// OnTwoFactor is called when plumesign waits for a 2FA code on stdin, send
+// the code with Write.
+func (t *InstallManager) OnTwoFactor(fn func()) {
+	t.em.On("two_factor", event.ListenerFunc(func(e event.Event) error {
+		fn()
+		return nil
+	}))
+}
+
+// OnProgress is called when the install moves to another phase or its percent
+// advances.
+func (t *InstallManager) OnProgress(fn func(model.InstallProgress)) {
+	t.em.On("progress", event.ListenerFunc(func(e event.Event) error {
+		fn(e.Get("progress").(model.InstallProgress))
+		return nil
+	}))
+}
+
 func (t *InstallManager) Write(p []byte) {
 	if t.stdin != nil {
 		_, _ = t.stdin.Write(p)
@@ -204,9 +296,10 @@ func (t *InstallManager) ErrorLog() string {
 	return strings.Join(lines, "\n")
 }
 
+// IsAccountInvalid reports whether the output shows a failed login, even
+// when it also contains the keywords of another failure class.
 func (t *InstallManager) IsAccountInvalid() bool {
-	log := t.OutputLog()
-	return strings.Contains(log, "plumesign account list") || strings.Contains(log, "Can't log-in") || strings.Contains(log, "DeveloperSession creation failed")
+	return HasFailureKeyword(t.OutputLog(), model.FailureAccountInvalid)
 }
 
 func (t *InstallManager) IsSuccess() bool {
@@ -222,48 +315,103 @@ func (t *InstallManager) WriteLog(msg string) {
 	_, _ = t.outputStdout.Write([]byte(msg))
 }
 
+// SaveLog writes the log of the last run of the app, overwriting the previous one.
 func (t *InstallManager) SaveLog(id uint) {
+	t.writeLogFile(filepath.Join(app.Config.Server.DataDir, "log", fmt.Sprintf("task_%d.log", id)))
+}
+
+// SaveAttemptLog keeps the log of a single refresh attempt.
+func (t *InstallManager) SaveAttemptLog(attemptID uint) {
+	t.writeLogFile(AttemptLogPath(attemptID))
+}
+
+func (t *InstallManager) writeLogFile(path string) {
 	data := t.OutputLog()
 
 	// Hide log password string
 	// data = strings.Replace(data, v.Password, "******", -1)
 
-	saveDir := filepath.Join(app.Config.Server.DataDir, "log")
+	saveDir := filepath.Dir(path)
 	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
 		log.Error("failed to create directory :" + saveDir)
 		return
 	}
 
-	path := filepath.Join(saveDir, fmt.Sprintf("task_%d.log", id))
 	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
 		log.Error("write log failed :" + path)
 		return
 	}
 }
 
+func AttemptLogPath(attemptID uint) string {
+	return filepath.Join(app.AttemptLogDir(app.Config), fmt.Sprintf("attempt_%d.log", attemptID))
+}
+
 type outputWriter struct {
-	data []byte
-	em   *event.Manager
+	data     []byte
+	em       *event.Manager
+	progress *progressParser
+	// start of the current line, and of the last line reported as 2FA prompt
+	lineStart   int
+	promptStart int
 }
 
 func newOutputWriter(em *event.Manager) *outputWriter {
 	return &outputWriter{
-		em: em,
+		em:          em,
+		progress:    newProgressParser(),
+		promptStart: -1,
 	}
 }
 
 func (w *outputWriter) Write(p []byte) (n int, err error) {
 	w.data = append(w.data, p...)
 	w.em.MustFire("output", event.M{"text": string(p)})
+	for _, progress := range w.progress.Write(p) {
+		w.em.MustFire("progress", event.M{"progress": progress})
+	}
+	w.detectPrompt()
 
 	n = len(p)
 	return n, nil
 }
 
+// detectPrompt fires "two_factor" once per line asking for a 2FA code. The
+// prompt usually has no line break, plumesign reads the code on the same line.
+func (w *outputWriter) detectPrompt() {
+	for {
+		i := bytes.IndexByte(w.data[w.lineStart:], '\n')
+		if i < 0 {
+			break
+		}
+		w.checkPrompt(w.data[w.lineStart : w.lineStart+i])
+		w.lineStart += i + 1
+	}
+	w.checkPrompt(w.data[w.lineStart:])
+}
+
+func (w *outputWriter) checkPrompt(line []byte) {
+	if w.promptStart == w.lineStart || !isTwoFactorPrompt(string(line)) {
+		return
+	}
+	w.promptStart = w.lineStart
+	w.em.MustFire("two_factor", event.M{})
+}
+
+// fail reports the failed phase unless the install already ended.
+func (w *outputWriter) fail() {
+	if w.progress.fail() {
+		w.em.MustFire("progress", event.M{"progress": w.progress.progress})
+	}
+}
+
 func (w *outputWriter) String() string {
 	return string(w.data)
 }
 
 func (w *outputWriter) Reset() {
 	w.data = []byte{}
+	w.progress = newProgressParser()
+	w.lineStart = 0
+	w.promptStart = -1
 }
@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// phaseKeywords maps plumesign output to install phases, matched in order
+// against the lower-cased line. The result line comes first so it wins over
+// the phase keywords it may contain.
+var phaseKeywords = []struct {
+	phase    model.InstallPhase
+	keywords []string
+}{
+	{model.InstallPhaseDone, []string{"installation succeeded", "installation complete"}},
+	{model.InstallPhaseAuthenticating, []string{"logging in", "log in", "login", "authenticat", "signing in"}},
+	{model.InstallPhaseRegisteringDevice, []string{"registering device", "register device", "adding device", "device registered"}},
+	{model.InstallPhaseCreatingAppID, []string{"app id", "appid", "app_id", "creating app"}},
+	{model.InstallPhaseFetchingProfile, []string{"provisioning profile", "mobileprovision", "fetching profile", "profile"}},
+	{model.InstallPhaseSigning, []string{"signing", "codesign", "sign bundle"}},
+	{model.InstallPhaseUploading, []string{"upload", "transferring", "copying"}},
+	{model.InstallPhaseInstalling, []string{"installing", "install progress"}},
+}
+
+var phaseOrder = []model.InstallPhase{
+	model.InstallPhaseAuthenticating,
+	model.InstallPhaseRegisteringDevice,
+	model.InstallPhaseCreatingAppID,
+	model.InstallPhaseFetchingProfile,
+	model.InstallPhaseSigning,
+	model.InstallPhaseUploading,
+	model.InstallPhaseInstalling,
+	model.InstallPhaseDone,
+}
+
+var percentRegex = regexp.MustCompile(`(\d{1,3})(?:\.\d+)?\s*%`)
+
+// emit a percent change only every this many points
+const percentStep = 5
+
+// progressParser turns plumesign output into install phases. Phases only move
+// forward, a line matching an earlier phase is part of the current one.
+type progressParser struct {
+	line     []byte
+	progress model.InstallProgress
+}
+
+func newProgressParser() *progressParser {
+	return &progressParser{progress: model.InstallProgress{Percent: -1}}
+}
+
+// Write feeds output to the parser and returns the progress changes of the
+// complete lines in p.
+func (p *progressParser) Write(data []byte) []model.InstallProgress {
+	var changes []model.InstallProgress
+	for _, b := range data {
+		// progress bars redraw the line with \r
+		if b != '\n' && b != '\r' {
+			p.line = append(p.line, b)
+			continue
+		}
+		if len(p.line) > 0 {
+			if p.parseLine(string(p.line)) {
+				changes = append(changes, p.progress)
+			}
+			p.line = p.line[:0]
+		}
+	}
+	return changes
+}
+
+func (p *progressParser) parseLine(line string) bool {
+	if p.progress.Phase.IsFinal() {
+		return false
+	}
+	lower := strings.ToLower(line)
+
+	changed := false
+	if phase, ok := matchPhase(lower); ok && phaseIndex(phase) > phaseIndex(p.progress.Phase) {
+		p.progress.Phase = phase
+		p.progress.Percent = -1
+		if phase == model.InstallPhaseDone {
+			p.progress.Percent = 100
+		}
+		changed = true
+	}
+
+	if p.progress.Phase == model.InstallPhaseUploading || p.progress.Phase == model.InstallPhaseInstalling {
+		if m := percentRegex.FindStringSubmatch(lower); m != nil {
+			percent, _ := strconv.Atoi(m[1])
+			percent = min(percent, 100)
+			if p.progress.Percent < 0 || percent >= p.progress.Percent+percentStep || (percent == 100 && p.progress.Percent != 100) {
+				p.progress.Percent = percent
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		p.progress.UpdatedAt = time.Now()
+	}
+	return changed
+}
+
+// fail marks the install as failed, it returns false when it already ended.
+func (p *progressParser) fail() bool {
+	if p.progress.Phase.IsFinal() {
+		return false
+	}
+	p.progress.Phase = model.InstallPhaseFailed
+	p.progress.Percent = -1
+	p.progress.UpdatedAt = time.Now()
+	return true
+}
+
+func matchPhase(line string) (model.InstallPhase, bool) {
+	for _, v := range phaseKeywords {
+		for _, keyword := range v.keywords {
+			if strings.Contains(line, keyword) {
+				return v.phase, true
+			}
+		}
+	}
+	return "", false
+}
+
+// phaseIndex returns the position of phase in the install order, -1 before
+// the first phase.
+func phaseIndex(phase model.InstallPhase) int {
+	for i, v := range phaseOrder {
+		if v == phase {
+			return i
+		}
+	}
+	return -1
+}
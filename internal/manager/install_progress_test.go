@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestProgressParser(t *testing.T) {
+	p := newProgressParser()
+	output := "Logging in to Apple ID...\n" +
+		"Registering device test-udid\n" +
+		"Creating App ID for com.example.app\n" +
+		"Fetching provisioning profile\n" +
+		"Signing bundle\n" +
+		"Uploading: 1%\rUploading: 3%\rUploading: 10%\rUploading: 100%\n" +
+		"Installing: 50%\n" +
+		"Logging out\n" +
+		"Installation Succeeded\n"
+
+	var phases []model.InstallPhase
+	var percents []int
+	for _, change := range p.Write([]byte(output)) {
+		phases = append(phases, change.Phase)
+		percents = append(percents, change.Percent)
+	}
+
+	wantPhases := []model.InstallPhase{
+		model.InstallPhaseAuthenticating,
+		model.InstallPhaseRegisteringDevice,
+		model.InstallPhaseCreatingAppID,
+		model.InstallPhaseFetchingProfile,
+		model.InstallPhaseSigning,
+		model.InstallPhaseUploading, // 1%
+		model.InstallPhaseUploading, // 10%
+		model.InstallPhaseUploading, // 100%
+		model.InstallPhaseInstalling,
+		model.InstallPhaseDone,
+	}
+	wantPercents := []int{-1, -1, -1, -1, -1, 1, 10, 100, 50, 100}
+	if len(phases) != len(wantPhases) {
+		t.Fatalf("phases = %v, want %v", phases, wantPhases)
+	}
+	for i := range wantPhases {
+		if phases[i] != wantPhases[i] || percents[i] != wantPercents[i] {
+			t.Fatalf("change %d = %s %d%%, want %s %d%%", i, phases[i], percents[i], wantPhases[i], wantPercents[i])
+		}
+	}
+
+	if p.fail() {
+		t.Fatal("fail should not override a finished install")
+	}
+}
+
+func TestProgressParserPartialLines(t *testing.T) {
+	p := newProgressParser()
+	if changes := p.Write([]byte("Sign")); len(changes) != 0 {
+		t.Fatalf("incomplete line should not be parsed, got %v", changes)
+	}
+	changes := p.Write([]byte("ing app\n"))
+	if len(changes) != 1 || changes[0].Phase != model.InstallPhaseSigning {
+		t.Fatalf("changes = %v, want signing", changes)
+	}
+	if !p.fail() || p.progress.Phase != model.InstallPhaseFailed {
+		t.Fatalf("phase = %s, want failed", p.progress.Phase)
+	}
+}
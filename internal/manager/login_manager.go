@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/bitxeno/atvloadly/internal/app"
 	execx "github.com/bitxeno/atvloadly/internal/exec"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/gookit/event"
@@ -53,14 +52,7 @@ func (t *LoginManager) Start(ctx context.Context, account, password string) erro
 		t.stdin = nil
 	}()
 
-	err = execx.CommandContext(ctx, "plumesign", "account", "login", "-u", account, "-p", password).
-		WithTimeout(timeout).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		WithStdout(t.outputStdout).
-		WithStderr(t.outputStdout).
-		WithStdin(stdinReader).
-		Run()
+	err = signer.Login(ctx, account, password, timeout, Stdio{Stdin: stdinReader, Stdout: t.outputStdout, Stderr: t.outputStdout})
 	if err != nil {
 		if errors.Is(err, execx.ErrCommandTimeout) {
 			log.Err(err).Msgf("login timeout exceeded after %d minutes. %s", int(timeout.Minutes()), t.ErrorLog())
@@ -51,6 +51,16 @@ func GetDeviceByUDID(udid string) (*model.Device, bool) {
 	return deviceManager.GetDeviceByUDID(udid)
 }
 
+// SaveDevice adds a connected device, or updates it.
+func SaveDevice(dev model.Device) {
+	deviceManager.SaveDevice(dev)
+}
+
+// DeleteDevice removes a device added with SaveDevice.
+func DeleteDevice(id string) {
+	deviceManager.DeleteDevice(id)
+}
+
 func ReloadDevices() {
 	deviceManager.ReloadDevices()
 }
@@ -7,8 +7,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/bitxeno/atvloadly/internal/app"
-	"github.com/bitxeno/atvloadly/internal/exec"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/model"
 	"github.com/gookit/event"
@@ -52,15 +50,7 @@ func (t *PairManager) Start(ctx context.Context, device model.Device) error {
 		t.stdin = nil
 	}()
 
-	port := fmt.Sprintf("%d", device.Port)
-	err = exec.CommandContext(ctx, "plumesign", "pair", "--ip", device.IP, "--port", port).
-		WithTimeout(timeout).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		WithStdout(t.outputStdout).
-		WithStderr(t.outputStderr).
-		WithStdin(stdinReader).
-		Run()
+	err = deviceBackend.Pair(ctx, device, timeout, Stdio{Stdin: stdinReader, Stdout: t.outputStdout, Stderr: t.outputStderr})
 	if err != nil {
 		log.Err(err).Msgf("Error executing pair script. %s", t.ErrorLog())
 		return err
@@ -151,10 +141,7 @@ func ImportPairingFile(ip string, port string, data []byte) error {
 	}
 
 	// Execute the check command
-	_, err = exec.NewCommand("plumesign", "check", "pairing", "--ip", ip, "--port", port, "-f", tmpFilePath).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	_, err = deviceBackend.CheckPairing(ip, port, tmpFilePath)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	execx "github.com/bitxeno/atvloadly/internal/exec"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// plumesign runs the plumesign CLI, it is the default Signer and DeviceBackend.
+type plumesign struct{}
+
+func (plumesign) command(args ...string) *execx.Cmd {
+	return execx.NewCommand("plumesign", args...).
+		WithDir(app.Config.Server.DataDir).
+		WithEnv(GetRunEnvs())
+}
+
+func (plumesign) commandContext(ctx context.Context, timeout time.Duration, args ...string) *execx.Cmd {
+	return execx.CommandContext(ctx, "plumesign", args...).
+		WithTimeout(timeout).
+		WithDir(app.Config.Server.DataDir).
+		WithEnv(GetRunEnvs())
+}
+
+func (p plumesign) Install(ctx context.Context, opts InstallOptions, provisionPath string, timeout time.Duration, stdio Stdio) error {
+	args := buildInstallArgs(opts, provisionPath)
+	if opts.RemoveExtensions {
+		args = append(args, "--remove-extensions")
+	}
+	if opts.RefreshMode {
+		args = append(args, "--refresh")
+	}
+
+	cmd := p.commandContext(ctx, timeout, args...).
+		WithStdout(stdio.Stdout).
+		WithStderr(stdio.Stderr).
+		WithStdin(stdio.Stdin)
+
+	log.Debugf("Install Command: %s", strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
+
+	return cmd.Run()
+}
+
+func buildInstallArgs(opts InstallOptions, provisionPath string) []string {
+	if opts.IP != "" && opts.Port != 0 && opts.UDID != "" {
+		return []string{"sign-rsd", "--apple-id", "--register-and-install", "--output-provision", provisionPath, "--ip", opts.IP, "--port", fmt.Sprintf("%d", opts.Port), "--udid", opts.UDID, "-u", opts.Account, "-p", opts.IpaPath}
+	}
+
+	return []string{"sign", "--apple-id", "--register-and-install", "--output-provision", provisionPath, "--udid", opts.UDID, "-u", opts.Account, "-p", opts.IpaPath}
+}
+
+func (p plumesign) Login(ctx context.Context, account, password string, timeout time.Duration, stdio Stdio) error {
+	return p.commandContext(ctx, timeout, "account", "login", "-u", account, "-p", password).
+		WithStdout(stdio.Stdout).
+		WithStderr(stdio.Stderr).
+		WithStdin(stdio.Stdin).
+		Run()
+}
+
+func (p plumesign) Logout(account string) ([]byte, error) {
+	return p.command("account", "logout", "-u", account).CombinedOutput()
+}
+
+func (p plumesign) AccountDevices(account string) ([]byte, error) {
+	return p.command("account", "devices", "-u", account).CombinedOutput()
+}
+
+func (p plumesign) DeleteAccountDevice(account, deviceID string) ([]byte, error) {
+	return p.command("account", "delete-device", "-u", account, "--device-id", deviceID).CombinedOutput()
+}
+
+func (p plumesign) Certificates(account string) ([]byte, error) {
+	return p.command("certificate", "list", "-u", account).CombinedOutput()
+}
+
+func (p plumesign) RevokeCertificate(account, serialNumber string) ([]byte, error) {
+	return p.command("certificate", "revoke", "-u", account, "-s", serialNumber).CombinedOutput()
+}
+
+func (p plumesign) ExportCertificate(account, password, path string) ([]byte, error) {
+	return p.command("certificate", "export", "-u", account, "-p", password, "-o", path).CombinedOutput()
+}
+
+func (p plumesign) ImportCertificate(account, password, path string) ([]byte, error) {
+	return p.command("certificate", "import", "-u", account, "-p", password, "-i", path).CombinedOutput()
+}
+
+func (plumesign) DeviceInfo(dev *model.Device) ([]byte, error) {
+	cmd := execx.Command("plumesign", "device-info", "-u", dev.UDID).WithTimeout(5 * time.Second)
+	if dev.Connection == model.DeviceConnectionRemote {
+		cmd = execx.Command("plumesign", "device-info", "--ip", dev.IP, "--port", fmt.Sprintf("%d", dev.Port), "-u", dev.UDID).WithTimeout(5 * time.Second)
+	}
+	return cmd.CombinedOutput()
+}
+
+func (plumesign) CheckAfc(dev *model.Device) ([]byte, error) {
+	cmd := execx.Command("plumesign", "check", "afc", "--udid", dev.UDID).WithTimeout(10 * time.Second)
+	if dev.Connection == model.DeviceConnectionRemote {
+		cmd = execx.Command("plumesign", "check", "afc", "--ip", dev.IP, "--port", fmt.Sprintf("%d", dev.Port), "--udid", dev.UDID).WithTimeout(10 * time.Second)
+	}
+	return cmd.CombinedOutput()
+}
+
+func (plumesign) FindPairing(identifier, authTag string) ([]byte, error) {
+	return execx.Command("plumesign", "check", "find-pairing", "--identifier", identifier, "--auth-tag", authTag).WithTimeout(10 * time.Second).CombinedOutput()
+}
+
+func (p plumesign) Pair(ctx context.Context, dev model.Device, timeout time.Duration, stdio Stdio) error {
+	return p.commandContext(ctx, timeout, "pair", "--ip", dev.IP, "--port", fmt.Sprintf("%d", dev.Port)).
+		WithStdout(stdio.Stdout).
+		WithStderr(stdio.Stderr).
+		WithStdin(stdio.Stdin).
+		Run()
+}
+
+func (p plumesign) CheckPairing(ip, port, path string) ([]byte, error) {
+	return p.command("check", "pairing", "--ip", ip, "--port", port, "-f", path).CombinedOutput()
+}
+
+func (p plumesign) Mount(ctx context.Context, dev *model.Device, imagePath, manifestPath, trustCachePath string) ([]byte, error) {
+	port := fmt.Sprintf("%d", dev.Port)
+	args := []string{"mount", "--ip", dev.IP, "--port", port, "--udid", dev.UDID}
+	args = append(args, "--image", imagePath, "--manifest", manifestPath, "--trustcache", trustCachePath)
+
+	log.Debugf("Mount Command: plumesign %s", strings.Join(args, " "))
+
+	return p.commandContext(ctx, 2*time.Minute, args...).CombinedOutput()
+}
+
+func (p plumesign) Screenshot(ctx context.Context, dev *model.Device, outputPath string) ([]byte, error) {
+	port := fmt.Sprintf("%d", dev.Port)
+	args := []string{"screenshot", "--ip", dev.IP, "--port", port, "--udid", dev.UDID, "--output", outputPath}
+
+	log.Debugf("Screenshot Command: plumesign %s", strings.Join(args, " "))
+
+	return p.commandContext(ctx, 30*time.Second, args...).CombinedOutput()
+}
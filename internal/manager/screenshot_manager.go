@@ -17,7 +17,6 @@ import (
 	_ "image/png" // register PNG decoder for image.Decode
 
 	"github.com/bitxeno/atvloadly/internal/app"
-	"github.com/bitxeno/atvloadly/internal/exec"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/model"
 	"golang.org/x/image/draw"
@@ -62,18 +61,7 @@ func (m *ScreenshotManager) EnsureMounted(ctx context.Context, dev *model.Device
 		return "", err
 	}
 
-	port := fmt.Sprintf("%d", dev.Port)
-	args := []string{"mount", "--ip", dev.IP, "--port", port, "--udid", dev.UDID}
-	args = append(args, "--image", imagePath, "--manifest", manifestPath, "--trustcache", trustCachePath)
-
-	log.Debugf("Mount Command: plumesign %s", strings.Join(args, " "))
-
-	timeout := 2 * time.Minute
-	output, err := exec.CommandContext(ctx, "plumesign", args...).
-		WithTimeout(timeout).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	output, err := deviceBackend.Mount(ctx, dev, imagePath, manifestPath, trustCachePath)
 	if err != nil {
 		log.Err(err).Msgf("Mount developer disk image failed")
 		return string(output), fmt.Errorf("mount developer disk image failed: %s", err.Error())
@@ -96,18 +84,7 @@ func (m *ScreenshotManager) TakeScreenshot(ctx context.Context, dev *model.Devic
 	}
 
 	outputPath := filepath.Join(m.outputDir, fmt.Sprintf("screenshot-%d.png", time.Now().UnixNano()))
-	port := fmt.Sprintf("%d", dev.Port)
-
-	args := []string{"screenshot", "--ip", dev.IP, "--port", port, "--udid", dev.UDID, "--output", outputPath}
-
-	log.Debugf("Screenshot Command: plumesign %s", strings.Join(args, " "))
-
-	timeout := 30 * time.Second
-	_, err := exec.CommandContext(ctx, "plumesign", args...).
-		WithTimeout(timeout).
-		WithDir(app.Config.Server.DataDir).
-		WithEnv(GetRunEnvs()).
-		CombinedOutput()
+	_, err := deviceBackend.Screenshot(ctx, dev, outputPath)
 	// Always remove the original PNG, success or failure.
 	defer func() { _ = os.Remove(outputPath) }()
 	if err != nil {
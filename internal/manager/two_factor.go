@@ -0,0 +1,22 @@
+package manager
+
+import "strings"
+
+// twoFactorPrompts match the line plumesign prints when Apple asks for a 2FA
+// code, matched against the lower-cased line.
+var twoFactorPrompts = []string{"2fa code", "verification code", "two-factor code", "two factor code", "security code"}
+
+// isTwoFactorPrompt reports whether line asks for a 2FA code. Prompts end with
+// a colon or question mark, error lines mentioning the code do not.
+func isTwoFactorPrompt(line string) bool {
+	lower := strings.ToLower(strings.TrimSpace(line))
+	if !strings.HasSuffix(lower, ":") && !strings.HasSuffix(lower, "?") {
+		return false
+	}
+	for _, prompt := range twoFactorPrompts {
+		if strings.Contains(lower, prompt) {
+			return true
+		}
+	}
+	return false
+}
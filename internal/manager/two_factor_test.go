@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/gookit/event"
+)
+
+func TestOutputWriterDetectsTwoFactorPrompt(t *testing.T) {
+	em := event.NewManager("output", event.UsePathMode)
+	w := newOutputWriter(em)
+	prompts := 0
+	em.On("two_factor", event.ListenerFunc(func(e event.Event) error {
+		prompts++
+		return nil
+	}))
+
+	// the prompt arrives in pieces without a line break
+	for _, s := range []string{"Logging in as a@example.com\n", "Enter 2FA ", "code: "} {
+		_, _ = w.Write([]byte(s))
+	}
+	if prompts != 1 {
+		t.Fatalf("prompts = %d, want 1", prompts)
+	}
+	_, _ = w.Write([]byte("\nError: Incorrect verification code\nEnter the verification code:\n"))
+	if prompts != 2 {
+		t.Fatalf("prompts = %d, want 2 after asking again", prompts)
+	}
+	_, _ = w.Write([]byte("Error: 2FA code was not entered in time\n"))
+	if prompts != 2 {
+		t.Fatalf("prompts = %d, error lines are no prompts", prompts)
+	}
+}
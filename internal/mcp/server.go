@@ -1,12 +1,19 @@
 package mcp
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/bitxeno/atvloadly/internal/app"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/mcp/tools"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/modelcontextprotocol/go-sdk/auth"
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -16,6 +23,8 @@ var (
 )
 
 // NewHTTPHandler creates a singleton MCP streamable HTTP handler.
+// When authentication is enabled every request must carry an API token
+// with the mcp scope.
 func NewHTTPHandler() http.Handler {
 	handlerOnce.Do(func() {
 		server := sdkmcp.NewServer(&sdkmcp.Implementation{
@@ -28,8 +37,55 @@ func NewHTTPHandler() http.Handler {
 			return server
 		}, nil)
 
+		if app.Config.Server.Auth.Enabled {
+			handler = auth.RequireBearerToken(verifyToken, &auth.RequireBearerTokenOptions{
+				Scopes: []string{model.ScopeMCP},
+			})(handler)
+		}
+
 		log.Infof("MCP endpoint enabled on /mcp")
 	})
 
 	return handler
 }
+
+func verifyToken(_ context.Context, raw string, req *http.Request) (*auth.TokenInfo, error) {
+	token, user, err := service.AuthenticateToken(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", auth.ErrInvalidToken, err.Error())
+	}
+
+	// RequireBearerToken matches scopes literally, so expand wildcards here.
+	scopes := []string{}
+	if token.Allows(model.ScopeMCP) && user.Role.Allows(model.ScopeRole(model.ScopeMCP)) {
+		scopes = append(scopes, model.ScopeMCP)
+	}
+
+	// tokens without expiration are re-validated on every request
+	expiration := time.Now().Add(time.Hour)
+	if token.ExpiresAt != nil {
+		expiration = *token.ExpiresAt
+	}
+
+	return &auth.TokenInfo{
+		Scopes:     scopes,
+		Expiration: expiration,
+		Extra: map[string]any{
+			"user":     user.Username,
+			"token":    token.Name,
+			"token_id": token.ID,
+			"ip":       remoteIP(req),
+		},
+	}, nil
+}
+
+func remoteIP(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
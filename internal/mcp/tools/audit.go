@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// recordAudit writes an audit entry for a state-changing tool call.
+func recordAudit(req *sdkmcp.CallToolRequest, action string, target string, err error) {
+	entry := model.AuditLog{
+		Actor:   "mcp",
+		Action:  action,
+		Target:  target,
+		Outcome: model.AuditOutcomeSuccess,
+	}
+	if err != nil {
+		entry.Outcome = model.AuditOutcomeFailure
+		entry.Detail = err.Error()
+	}
+
+	// TokenInfo is only present when auth is enabled
+	if req != nil && req.Extra != nil && req.Extra.TokenInfo != nil {
+		info := req.Extra.TokenInfo
+		user, _ := info.Extra["user"].(string)
+		token, _ := info.Extra["token"].(string)
+		if user != "" {
+			entry.Actor = fmt.Sprintf("%s (mcp token: %s)", user, token)
+		}
+		entry.SourceIP, _ = info.Extra["ip"].(string)
+	}
+
+	service.RecordAudit(entry)
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/bitxeno/atvloadly/internal/task"
+	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type cancelInstallInput struct {
+	JobID uint `json:"job_id,omitempty" jsonschema:"Optional job id of the install or refresh to cancel"`
+	AppID uint `json:"app_id,omitempty" jsonschema:"Optional app id, cancels the queued or running refresh of that app"`
+}
+
+type cancelInstallOutput struct {
+	JobID   uint   `json:"job_id"`
+	State   string `json:"state"`
+	Message string `json:"message"`
+}
+
+func registerCancelInstall(server *sdkmcp.Server) {
+	sdkmcp.AddTool(server, &sdkmcp.Tool{
+		Name: "cancel_install",
+		Description: "Cancel a queued or running install/refresh task. " +
+			"Provide job_id, or app_id to cancel the active refresh of an installed app. " +
+			"A running task is stopped and its temp files are cleaned up.",
+	}, handleCancelInstall)
+}
+
+func handleCancelInstall(_ context.Context, req *sdkmcp.CallToolRequest, input cancelInstallInput) (*sdkmcp.CallToolResult, cancelInstallOutput, error) {
+	jobID := input.JobID
+	if jobID == 0 && input.AppID > 0 {
+		jobs, err := service.GetActiveJobs()
+		if err != nil {
+			return nil, cancelInstallOutput{}, err
+		}
+		for _, job := range jobs {
+			if job.AppID == input.AppID {
+				jobID = job.ID
+				break
+			}
+		}
+		if jobID == 0 {
+			return nil, cancelInstallOutput{}, fmt.Errorf("no queued or running task for app id=%d", input.AppID)
+		}
+	}
+	if jobID == 0 {
+		return nil, cancelInstallOutput{}, fmt.Errorf("job_id or app_id is required")
+	}
+
+	job, err := task.CancelJob(jobID)
+	recordAudit(req, "job.cancel", fmt.Sprintf("id=%d", jobID), err)
+	if err != nil {
+		return nil, cancelInstallOutput{}, err
+	}
+
+	log.Infof("MCP cancel_install job id=%d name=%s", job.ID, job.App.IpaName)
+	message := "Task is being stopped."
+	if job.State == model.JobStateCancelled {
+		message = "Queued task cancelled."
+	}
+	return nil, cancelInstallOutput{
+		JobID:   job.ID,
+		State:   string(job.State),
+		Message: message,
+	}, nil
+}
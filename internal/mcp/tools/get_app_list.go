@@ -7,6 +7,7 @@ import (
 	"github.com/bitxeno/atvloadly/internal/manager"
 	"github.com/bitxeno/atvloadly/internal/model"
 	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/bitxeno/atvloadly/internal/task"
 	"github.com/bitxeno/atvloadly/internal/utils"
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -25,6 +26,7 @@ type appListItem struct {
 	Enabled          bool       `json:"enabled"`
 	ExpirationDate   *time.Time `json:"expiration_date,omitempty"`
 	IsExpired        bool       `json:"is_expired"`
+	NextRefreshAt    *time.Time `json:"next_refresh_at,omitempty"`
 }
 
 type getAppListOutput struct {
@@ -78,5 +80,6 @@ func toAppListItem(app model.InstalledApp) appListItem {
 		Enabled:          app.Enabled,
 		ExpirationDate:   app.ExpirationDate,
 		IsExpired:        app.IsExpired(),
+		NextRefreshAt:    task.NextPlannedRun(app, time.Now()),
 	}
 }
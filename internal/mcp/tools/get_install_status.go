@@ -2,7 +2,10 @@ package tools
 
 import (
 	"context"
+	"time"
 
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/bitxeno/atvloadly/internal/task"
 
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
@@ -11,15 +14,32 @@ import (
 type getInstallStatusInput struct{}
 
 type getInstallStatusOutput struct {
-	InstallState      string `json:"install_state"`
-	InstallInProgress bool   `json:"install_in_progress"`
+	InstallState      string              `json:"install_state"`
+	InstallInProgress bool                `json:"install_in_progress"`
+	Installs          []installStatusItem `json:"installs"`
+}
+
+type installStatusItem struct {
+	JobID   uint               `json:"job_id"`
+	AppID   uint               `json:"app_id"`
+	AppName string             `json:"app_name"`
+	Device  string             `json:"device"`
+	State   model.JobState     `json:"state"`
+	Phase   model.InstallPhase `json:"phase,omitempty"`
+	Percent int                `json:"percent"`
+	// set while the job waits for a 2FA code, see submit_2fa_code
+	CodeExpiresAt *time.Time `json:"code_expires_at,omitempty"`
 }
 
 func registerGetInstallStatus(server *sdkmcp.Server) {
 	sdkmcp.AddTool(server, &sdkmcp.Tool{
 		Name: "get_install_status",
 		Description: "Get install app status. " +
-			"Returns in_progress when there is any active install app task, otherwise completed.",
+			"Returns in_progress when there is any active install app task, otherwise completed. " +
+			"installs lists the queued and running installs with their phase " +
+			"(authenticating, registering_device, creating_app_id, fetching_profile, signing, uploading, installing, done, failed) " +
+			"and percent (-1 when unknown). " +
+			"A refresh in the awaiting_2fa state waits for an Apple verification code until code_expires_at, enter it with submit_2fa_code.",
 	}, handleGetInstallStatus)
 }
 
@@ -29,11 +49,37 @@ func handleGetInstallStatus(_ context.Context, _ *sdkmcp.CallToolRequest, input
 		return nil, getInstallStatusOutput{
 			InstallState:      "completed",
 			InstallInProgress: false,
+			Installs:          []installStatusItem{},
 		}, nil
 	}
 
+	jobs, err := service.GetActiveJobs()
+	if err != nil {
+		return nil, getInstallStatusOutput{}, err
+	}
+	installs := make([]installStatusItem, 0, len(jobs))
+	for _, job := range jobs {
+		item := installStatusItem{
+			JobID:   job.ID,
+			AppID:   job.AppID,
+			AppName: job.App.IpaName,
+			Device:  job.App.Device,
+			State:   job.State,
+			Percent: -1,
+
+			CodeExpiresAt: job.CodeExpiresAt,
+		}
+		// a pending job may keep the progress of its failed previous attempt
+		if job.Progress != nil && job.State.IsRunning() {
+			item.Phase = job.Progress.Phase
+			item.Percent = job.Progress.Percent
+		}
+		installs = append(installs, item)
+	}
+
 	return nil, getInstallStatusOutput{
 		InstallState:      "in_progress",
 		InstallInProgress: true,
+		Installs:          installs,
 	}, nil
 }
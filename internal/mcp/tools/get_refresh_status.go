@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/bitxeno/atvloadly/internal/i18n"
 	"github.com/bitxeno/atvloadly/internal/model"
 	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/bitxeno/atvloadly/internal/task"
@@ -23,6 +24,8 @@ type getRefreshStatusItem struct {
 	LastRefreshAt     *time.Time           `json:"last_refresh_at,omitempty"`
 	LastSuccess       bool                 `json:"last_success"`
 	LastErrorCode     model.RefreshedError `json:"last_error_code"`
+	FailureCode       model.FailureCode    `json:"failure_code,omitempty"`
+	FailureHint       string               `json:"failure_hint,omitempty"`
 	IsExpired         bool                 `json:"is_expired"`
 }
 
@@ -41,6 +44,8 @@ func registerGetRefreshStatus(server *sdkmcp.Server) {
 		Name: "get_refresh_status",
 		Description: "Get real-time app refresh status. " +
 			"refresh_state is one of: in_progress, completed_success, completed_failed, unknown. " +
+			"Failed apps carry a stable failure_code (e.g. device_offline, afc_error, pairing_invalid, two_factor_required, app_id_limit) " +
+			"and a failure_hint telling the user how to fix it. " +
 			"Use this tool after refresh_app to let AI know current progress and final result.",
 	}, handleGetRefreshStatus)
 }
@@ -70,6 +75,10 @@ func handleGetRefreshStatus(_ context.Context, _ *sdkmcp.CallToolRequest, input
 			LastErrorCode:     app.RefreshedError,
 			IsExpired:         app.IsExpired(),
 		}
+		if !app.RefreshedResult && app.FailureCode != "" {
+			item.FailureCode = app.FailureCode
+			item.FailureHint = i18n.Localize(app.FailureCode.HintKey())
+		}
 
 		switch state {
 		case "in_progress":
@@ -7,9 +7,12 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/bitxeno/atvloadly/internal/ipa"
 	"github.com/bitxeno/atvloadly/internal/manager"
 	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/bitxeno/atvloadly/internal/task"
 	"github.com/bitxeno/atvloadly/internal/utils"
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
@@ -20,6 +23,9 @@ type installAppInput struct {
 	DeviceID         string `json:"device_id,omitempty" jsonschema:"Optional target device ID"`
 	AccountID        string `json:"account_id,omitempty" jsonschema:"Optional Apple account ID (md5 of account email)"`
 	RemoveExtensions bool   `json:"remove_extensions,omitempty" jsonschema:"Optional remove app extensions while installing"`
+	OverrideBundleID string `json:"override_bundle_id,omitempty" jsonschema:"Optional bundle identifier to install the app as, e.g. to keep a nightly build next to the stable one"`
+	OverrideName     string `json:"override_name,omitempty" jsonschema:"Optional display name to install the app with"`
+	OverrideVersion  string `json:"override_version,omitempty" jsonschema:"Optional version to install the app with, e.g. 1.2.3"`
 }
 
 type installDeviceOption struct {
@@ -58,7 +64,7 @@ func registerInstallApp(server *sdkmcp.Server) {
 	}, handleInstallApp)
 }
 
-func handleInstallApp(_ context.Context, _ *sdkmcp.CallToolRequest, input installAppInput) (*sdkmcp.CallToolResult, installAppOutput, error) {
+func handleInstallApp(_ context.Context, req *sdkmcp.CallToolRequest, input installAppInput) (*sdkmcp.CallToolResult, installAppOutput, error) {
 	ipaURL := strings.TrimSpace(input.IpaURL)
 	if ipaURL == "" {
 		return nil, installAppOutput{}, fmt.Errorf("ipa_url is required")
@@ -66,6 +72,14 @@ func handleInstallApp(_ context.Context, _ *sdkmcp.CallToolRequest, input instal
 	if !isIPAURL(ipaURL) {
 		return nil, installAppOutput{}, fmt.Errorf("ipa_url must point to an .ipa file")
 	}
+	overrides := ipa.Overrides{
+		BundleIdentifier: strings.TrimSpace(input.OverrideBundleID),
+		DisplayName:      strings.TrimSpace(input.OverrideName),
+		Version:          strings.TrimSpace(input.OverrideVersion),
+	}
+	if err := overrides.Validate(); err != nil {
+		return nil, installAppOutput{}, err
+	}
 
 	selectedDevice, deviceOptions, needDeviceChoice, err := resolveDeviceSelection(strings.TrimSpace(input.DeviceID))
 	if err != nil {
@@ -101,9 +115,19 @@ func handleInstallApp(_ context.Context, _ *sdkmcp.CallToolRequest, input instal
 		Account:          selectedAccount.rawEmail,
 		Enabled:          true,
 		RemoveExtensions: input.RemoveExtensions,
+		OverrideBundleID: overrides.BundleIdentifier,
+		OverrideName:     overrides.DisplayName,
+		OverrideVersion:  overrides.Version,
+	}
+	appModel.ApplyOverrides()
+
+	if err := service.CheckQuota(appModel, time.Now()); err != nil {
+		recordAudit(req, "app.install", fmt.Sprintf("url=%s device=%s account=%s", ipaURL, selectedDevice.UDID, selectedAccount.rawEmail), err)
+		return nil, installAppOutput{}, err
 	}
 
-	task.StartInstallApps([]model.InstalledApp{appModel}, true)
+	task.StartInstallApps([]model.InstalledApp{appModel}, true, model.RefreshTriggerMCP)
+	recordAudit(req, "app.install", fmt.Sprintf("url=%s device=%s account=%s", ipaURL, selectedDevice.UDID, selectedAccount.rawEmail), nil)
 
 	return nil, installAppOutput{
 		Status:          "installing",
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/bitxeno/atvloadly/internal/task"
+	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type previewRefreshPlanInput struct {
+	Cron        string `json:"cron,omitempty" jsonschema:"Optional cron expression to preview instead of the saved refresh time, e.g. 0 3 * * *"`
+	AdvanceDays int    `json:"advance_days,omitempty" jsonschema:"Optional advance days to preview instead of the saved value"`
+	Firings     int    `json:"firings,omitempty" jsonschema:"Number of upcoming cron firings to simulate, default 5, max 50"`
+}
+
+func registerPreviewRefreshPlan(server *sdkmcp.Server) {
+	sdkmcp.AddTool(server, &sdkmcp.Tool{
+		Name: "preview_refresh_plan",
+		Description: "Dry run of the scheduled refresh, nothing is installed. " +
+			"Returns the next fire times of the cron expression and, for each firing, the apps that would be refreshed " +
+			"and the skipped ones with a reason: not_due, account_invalid, device_offline, afc_unavailable or own_schedule. " +
+			"Device state is only checked for the first firing (device_checked). " +
+			"Pass cron or advance_days to preview a change before saving it.",
+	}, handlePreviewRefreshPlan)
+}
+
+func handlePreviewRefreshPlan(_ context.Context, _ *sdkmcp.CallToolRequest, input previewRefreshPlanInput) (*sdkmcp.CallToolResult, task.RefreshPlan, error) {
+	plan, err := task.PreviewRefreshPlan(task.PlanOptions{
+		Cron:        input.Cron,
+		AdvanceDays: input.AdvanceDays,
+		Firings:     input.Firings,
+	})
+	if err != nil {
+		return nil, task.RefreshPlan{}, err
+	}
+	return nil, *plan, nil
+}
@@ -2,8 +2,10 @@ package tools
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
 	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/bitxeno/atvloadly/internal/task"
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
@@ -30,14 +32,16 @@ func registerRefreshApp(server *sdkmcp.Server) {
 	}, handleRefreshApp)
 }
 
-func handleRefreshApp(_ context.Context, _ *sdkmcp.CallToolRequest, input refreshAppInput) (*sdkmcp.CallToolResult, refreshAppOutput, error) {
+func handleRefreshApp(_ context.Context, req *sdkmcp.CallToolRequest, input refreshAppInput) (*sdkmcp.CallToolResult, refreshAppOutput, error) {
 	if input.AppID > 0 {
 		app, err := service.GetApp(input.AppID)
 		if err != nil {
+			recordAudit(req, "app.refresh", fmt.Sprintf("id=%d", input.AppID), err)
 			return nil, refreshAppOutput{}, err
 		}
 
-		task.RefreshApp(*app)
+		task.RefreshApp(*app, model.RefreshTriggerMCP)
+		recordAudit(req, "app.refresh", fmt.Sprintf("id=%d", app.ID), nil)
 		log.Infof("MCP refresh_app queued app id=%d name=%s", app.ID, app.IpaName)
 		return nil, refreshAppOutput{
 			Mode:         "single",
@@ -60,11 +64,12 @@ func handleRefreshApp(_ context.Context, _ *sdkmcp.CallToolRequest, input refres
 			continue
 		}
 
-		task.RefreshApp(app)
+		task.RefreshApp(app, model.RefreshTriggerMCP)
 		queued++
 	}
 
 	log.Infof("MCP refresh_app queued=%d skipped=%d", queued, skipped)
+	recordAudit(req, "app.refresh.all", fmt.Sprintf("queued=%d skipped=%d", queued, skipped), nil)
 	return nil, refreshAppOutput{
 		Mode:         "expired_all",
 		QueuedCount:  queued,
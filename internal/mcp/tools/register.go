@@ -15,4 +15,7 @@ func Register(server *sdkmcp.Server) {
 	registerGetRefreshStatus(server)
 	registerInstallApp(server)
 	registerGetInstallStatus(server)
+	registerCancelInstall(server)
+	registerSubmit2FACode(server)
+	registerPreviewRefreshPlan(server)
 }
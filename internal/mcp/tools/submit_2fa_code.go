@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/bitxeno/atvloadly/internal/task"
+	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type submit2FACodeInput struct {
+	JobID uint   `json:"job_id,omitempty" jsonschema:"Optional job id waiting for the code, required when several jobs are waiting"`
+	Code  string `json:"code" jsonschema:"The 6 digit verification code sent by Apple"`
+}
+
+type submit2FACodeOutput struct {
+	JobID   uint   `json:"job_id"`
+	Message string `json:"message"`
+}
+
+func registerSubmit2FACode(server *sdkmcp.Server) {
+	sdkmcp.AddTool(server, &sdkmcp.Tool{
+		Name: "submit_2fa_code",
+		Description: "Enter the Apple 2FA verification code for a refresh paused in the awaiting_2fa state. " +
+			"get_install_status lists the waiting jobs. The job fails when no code is entered before code_expires_at.",
+	}, handleSubmit2FACode)
+}
+
+func handleSubmit2FACode(_ context.Context, req *sdkmcp.CallToolRequest, input submit2FACodeInput) (*sdkmcp.CallToolResult, submit2FACodeOutput, error) {
+	jobID := input.JobID
+	if jobID == 0 {
+		jobs, err := service.GetActiveJobs()
+		if err != nil {
+			return nil, submit2FACodeOutput{}, err
+		}
+		for _, job := range jobs {
+			if job.State != model.JobStateAwaiting2FA {
+				continue
+			}
+			if jobID != 0 {
+				return nil, submit2FACodeOutput{}, fmt.Errorf("several jobs are waiting for a 2FA code, job_id is required")
+			}
+			jobID = job.ID
+		}
+		if jobID == 0 {
+			return nil, submit2FACodeOutput{}, task.ErrNoCodePrompt
+		}
+	}
+
+	err := task.SubmitCode(jobID, input.Code)
+	recordAudit(req, "job.2fa", fmt.Sprintf("id=%d", jobID), err)
+	if err != nil {
+		return nil, submit2FACodeOutput{}, err
+	}
+
+	log.Infof("MCP submit_2fa_code job id=%d", jobID)
+	return nil, submit2FACodeOutput{
+		JobID:   jobID,
+		Message: "Code entered, the refresh continues.",
+	}, nil
+}
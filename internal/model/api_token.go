@@ -0,0 +1,116 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Token scopes. A scope ending in ":*" grants every action on the resource
+// and ScopeAll grants everything.
+const (
+	ScopeAll           = "*"
+	ScopeAppsRead      = "apps:read"
+	ScopeAppsWrite     = "apps:write"
+	ScopeDevicesRead   = "devices:read"
+	ScopeDevicesWrite  = "devices:write"
+	ScopeAccountsRead  = "accounts:read"
+	ScopeAccountsWrite = "accounts:write"
+	ScopeSettingsRead  = "settings:read"
+	ScopeSettingsWrite = "settings:write"
+	ScopeNotifySend    = "notify:send"
+	ScopeTerminal      = "terminal"
+	ScopeUsers         = "users"
+	ScopeAuditRead     = "audit:read"
+	ScopeTokensRead    = "tokens:read"
+	ScopeTokensWrite   = "tokens:write"
+	ScopeMCP           = "mcp"
+)
+
+// scopeRoles is the minimum user role required to hold each scope.
+var scopeRoles = map[string]UserRole{
+	ScopeAppsRead:      UserRoleViewer,
+	ScopeAppsWrite:     UserRoleAdmin,
+	ScopeDevicesRead:   UserRoleViewer,
+	ScopeDevicesWrite:  UserRoleAdmin,
+	ScopeAccountsRead:  UserRoleViewer,
+	ScopeAccountsWrite: UserRoleAdmin,
+	ScopeSettingsRead:  UserRoleAdmin,
+	ScopeSettingsWrite: UserRoleAdmin,
+	ScopeNotifySend:    UserRoleAdmin,
+	ScopeTerminal:      UserRoleAdmin,
+	ScopeUsers:         UserRoleAdmin,
+	ScopeAuditRead:     UserRoleAdmin,
+	ScopeTokensRead:    UserRoleViewer,
+	ScopeTokensWrite:   UserRoleViewer,
+	ScopeMCP:           UserRoleAdmin,
+}
+
+// ScopeRole returns the minimum role needed for a scope. Unknown and
+// wildcard scopes require admin.
+func ScopeRole(scope string) UserRole {
+	if role, ok := scopeRoles[scope]; ok {
+		return role
+	}
+	return UserRoleAdmin
+}
+
+// ValidScope reports whether scope is a known scope, a resource wildcard
+// such as "devices:*", or ScopeAll.
+func ValidScope(scope string) bool {
+	if scope == ScopeAll {
+		return true
+	}
+	if _, ok := scopeRoles[scope]; ok {
+		return true
+	}
+	if resource, ok := strings.CutSuffix(scope, ":*"); ok {
+		for known := range scopeRoles {
+			if strings.HasPrefix(known, resource+":") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ScopeAllows reports whether the granted scopes cover the required scope.
+func ScopeAllows(granted []string, required string) bool {
+	for _, s := range granted {
+		if s == ScopeAll || s == required {
+			return true
+		}
+		if resource, ok := strings.CutSuffix(s, ":*"); ok && strings.HasPrefix(required, resource+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken is a long-lived bearer token for scripts and MCP clients. Only the
+// sha256 of the token is stored.
+type APIToken struct {
+	gorm.Model
+
+	Name       string     `json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `gorm:"serializer:json" json:"scopes"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func (t APIToken) IsExpired() bool {
+	return t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now())
+}
+
+func (t APIToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t APIToken) Allows(scope string) bool {
+	return ScopeAllows(t.Scopes, scope)
+}
@@ -0,0 +1,40 @@
+package model
+
+import "testing"
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required string
+		want     bool
+	}{
+		{name: "exact", granted: []string{ScopeAppsRead}, required: ScopeAppsRead, want: true},
+		{name: "other action", granted: []string{ScopeAppsRead}, required: ScopeAppsWrite, want: false},
+		{name: "resource wildcard", granted: []string{"devices:*"}, required: ScopeDevicesWrite, want: true},
+		{name: "wildcard other resource", granted: []string{"devices:*"}, required: ScopeAppsRead, want: false},
+		{name: "all", granted: []string{ScopeAll}, required: ScopeMCP, want: true},
+		{name: "empty", granted: nil, required: ScopeMCP, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeAllows(tt.granted, tt.required); got != tt.want {
+				t.Fatalf("ScopeAllows(%v, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range []string{ScopeAll, ScopeMCP, "apps:*", ScopeDevicesRead} {
+		if !ValidScope(scope) {
+			t.Fatalf("ValidScope(%q) = false, want true", scope)
+		}
+	}
+	for _, scope := range []string{"", "apps", "foo:*", "apps:delete"} {
+		if ValidScope(scope) {
+			t.Fatalf("ValidScope(%q) = true, want false", scope)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// AuditLog records who performed a state-changing action and how it ended.
+type AuditLog struct {
+	ID        uint         `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time    `gorm:"index" json:"created_at"`
+	Actor     string       `gorm:"index" json:"actor"`
+	SourceIP  string       `json:"source_ip"`
+	Action    string       `gorm:"index" json:"action"`
+	Target    string       `json:"target"`
+	Outcome   AuditOutcome `gorm:"index" json:"outcome"`
+	Detail    string       `json:"detail,omitempty"`
+}
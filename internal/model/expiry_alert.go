@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// ExpiryAlert records an expiry notification sent for an app, so each
+// threshold is notified once per expiration date. ThresholdHours is 0 once
+// the app has expired.
+type ExpiryAlert struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+	AppID          uint      `gorm:"index" json:"app_id"`
+	ExpirationDate time.Time `json:"expiration_date"`
+	ThresholdHours int       `json:"threshold_hours"`
+}
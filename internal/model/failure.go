@@ -0,0 +1,34 @@
+package model
+
+// FailureCode is a stable code for the cause of a failed install, shown with
+// a localized remediation hint.
+type FailureCode string
+
+const (
+	FailureAccountInvalid     FailureCode = "account_invalid"
+	FailureTwoFactorRequired  FailureCode = "two_factor_required"
+	FailureDeviceOffline      FailureCode = "device_offline"
+	FailureAfcError           FailureCode = "afc_error"
+	FailurePairingInvalid     FailureCode = "pairing_invalid"
+	FailureAppIDLimit         FailureCode = "app_id_limit"
+	FailureActiveAppLimit     FailureCode = "active_app_limit"
+	FailureMaxCertificates    FailureCode = "max_certificates"
+	FailureCertificateRevoked FailureCode = "certificate_revoked"
+	FailureAnisette           FailureCode = "anisette_failed"
+	FailureTimeout            FailureCode = "timeout"
+	FailureDeviceDiskFull     FailureCode = "device_disk_full"
+	FailureInvalidIPA         FailureCode = "invalid_ipa"
+	FailureDownload           FailureCode = "download_failed"
+	FailureAppNotFound        FailureCode = "app_not_found"
+	FailureUnknown            FailureCode = "unknown"
+)
+
+// NameKey is the i18n key of the short description of the failure.
+func (c FailureCode) NameKey() string {
+	return "failure." + string(c) + ".name"
+}
+
+// HintKey is the i18n key of the remediation hint of the failure.
+func (c FailureCode) HintKey() string {
+	return "failure." + string(c) + ".hint"
+}
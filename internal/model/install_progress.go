@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// InstallPhase is a step of a plumesign install, in the order they run.
+type InstallPhase string
+
+const (
+	InstallPhaseAuthenticating    InstallPhase = "authenticating"
+	InstallPhaseRegisteringDevice InstallPhase = "registering_device"
+	InstallPhaseCreatingAppID     InstallPhase = "creating_app_id"
+	InstallPhaseFetchingProfile   InstallPhase = "fetching_profile"
+	InstallPhaseSigning           InstallPhase = "signing"
+	InstallPhaseUploading         InstallPhase = "uploading"
+	InstallPhaseInstalling        InstallPhase = "installing"
+	InstallPhaseDone              InstallPhase = "done"
+	InstallPhaseFailed            InstallPhase = "failed"
+)
+
+// IsFinal reports whether the install has ended.
+func (p InstallPhase) IsFinal() bool {
+	return p == InstallPhaseDone || p == InstallPhaseFailed
+}
+
+// InstallProgress is the current phase of an install. Percent is only known
+// while uploading and installing, it is -1 otherwise.
+type InstallProgress struct {
+	Phase     InstallPhase `json:"phase"`
+	Percent   int          `json:"percent"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/bitxeno/atvloadly/internal/secret"
 	masker "github.com/ggwhite/go-masker/v2"
 	"gorm.io/gorm"
 )
@@ -18,17 +19,27 @@ type InstalledApp struct {
 	DeviceClass      string         `json:"device_class"`
 	UDID             string         `gorm:"column:udid" json:"udid"`
 	Account          string         `json:"account"`
-	Password         string         `json:"password"`
+	Password         secret.String  `json:"password"`
 	InstalledDate    *time.Time     `json:"installed_date"`
 	RefreshedDate    *time.Time     `json:"refreshed_date"`
 	ExpirationDate   *time.Time     `json:"expiration_date"`
 	RefreshedResult  bool           `json:"refreshed_result"`
 	RefreshedError   RefreshedError `json:"refreshed_error"`
+	FailureCode      FailureCode    `json:"failure_code"`
 	Icon             string         `json:"icon"`
 	BundleIdentifier string         `json:"bundle_identifier"`
 	Version          string         `json:"version"`
 	RemoveExtensions bool           `json:"remove_extensions"`
 	Enabled          bool           `json:"enabled,omitempty"`
+	// per-app overrides of the global refresh settings, empty/0 uses the global value
+	RefreshCron string `json:"refresh_cron"`
+	AdvanceDays int    `json:"advance_days"`
+	// rewritten into the Info.plist on every install and refresh, empty keeps the ipa value
+	OverrideBundleID string `json:"override_bundle_id"`
+	OverrideName     string `json:"override_name"`
+	OverrideVersion  string `json:"override_version"`
+
+	NextRefreshAt *time.Time `gorm:"-" json:"next_refresh_at"`
 }
 
 type RefreshedError int
@@ -56,16 +67,36 @@ func (t InstalledApp) MaskAccount() string {
 	return m.Marshal("*", t.Account)
 }
 
+// ApplyOverrides makes the overridden bundle identifier, name and version the
+// ones the app is installed and listed with.
+func (t *InstalledApp) ApplyOverrides() {
+	if t.OverrideBundleID != "" {
+		t.BundleIdentifier = t.OverrideBundleID
+	}
+	if t.OverrideName != "" {
+		t.IpaName = t.OverrideName
+	}
+	if t.OverrideVersion != "" {
+		t.Version = t.OverrideVersion
+	}
+}
+
 func (t InstalledApp) IsIPhoneApp() bool {
 	return t.DeviceClass == string(DeviceClassiPhone) || t.DeviceClass == string(DeviceClassiPad)
 }
 
 func (t InstalledApp) NeedRefresh(advanceDays int) bool {
-	now := time.Now()
+	due := t.RefreshDueTime(advanceDays)
+	return due == nil || due.Before(time.Now())
+}
 
+// RefreshDueTime returns when the app should be refreshed, advanceDays before
+// it expires, or nil if it was never refreshed. The app's own AdvanceDays
+// overrides advanceDays.
+func (t InstalledApp) RefreshDueTime(advanceDays int) *time.Time {
 	// fix RefreshedDate is nil
 	if t.RefreshedDate == nil {
-		return true
+		return nil
 	}
 
 	// fix ExpirationDate is nil
@@ -75,12 +106,16 @@ func (t InstalledApp) NeedRefresh(advanceDays int) bool {
 		expirationDate = &expireTime
 	}
 
+	if t.AdvanceDays > 0 {
+		advanceDays = t.AdvanceDays
+	}
 	// Use configured advance days (default to 1 if not set or invalid)
 	if advanceDays <= 0 {
 		advanceDays = 1
 	}
 
-	return expirationDate.AddDate(0, 0, -advanceDays).Before(now)
+	due := expirationDate.AddDate(0, 0, -advanceDays)
+	return &due
 }
 
 func (t InstalledApp) IsAccountInvalid() bool {
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IpaVersion is an IPA kept in the version store of an installed app, so a
+// bad update can be rolled back. The same IPA is stored once per app.
+type IpaVersion struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	AppID       uint      `gorm:"index" json:"app_id"`
+	Version     string    `json:"version"`
+	Build       string    `json:"build"`
+	SHA256      string    `gorm:"column:sha256" json:"sha256"`
+	Size        int64     `json:"size"`
+	Path        string    `json:"-"`
+	InstalledAt time.Time `json:"installed_at"`
+	// Current marks the IPA the app is installed from.
+	Current bool `gorm:"-" json:"current"`
+}
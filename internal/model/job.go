@@ -0,0 +1,79 @@
+package model
+
+import "time"
+
+type JobState string
+
+const (
+	JobStatePending   JobState = "pending"
+	JobStateRunning   JobState = "running"
+	JobStateSucceeded JobState = "succeeded"
+	JobStateFailed    JobState = "failed"
+	JobStateCancelled JobState = "cancelled"
+	// a running job paused until a 2FA code is entered
+	JobStateAwaiting2FA JobState = "awaiting_2fa"
+)
+
+// ActiveJobStates are the states of jobs not finished yet.
+var ActiveJobStates = []JobState{JobStatePending, JobStateRunning, JobStateAwaiting2FA}
+
+// JobPriority orders the queue, higher runs first.
+type JobPriority int
+
+const (
+	JobPriorityScheduled    JobPriority = 0
+	JobPriorityExpiringSoon JobPriority = 1
+	JobPriorityInteractive  JobPriority = 2
+)
+
+func (p JobPriority) String() string {
+	switch p {
+	case JobPriorityInteractive:
+		return "interactive"
+	case JobPriorityExpiringSoon:
+		return "expiring_soon"
+	default:
+		return "scheduled"
+	}
+}
+
+func (s JobState) IsActive() bool {
+	return s == JobStatePending || s.IsRunning()
+}
+
+// IsRunning reports whether a worker runs the job, including while it waits
+// for a 2FA code.
+func (s JobState) IsRunning() bool {
+	return s == JobStateRunning || s == JobStateAwaiting2FA
+}
+
+// Job is a queued install or refresh of an app. App keeps a snapshot of the
+// app to install, for refreshes it is reloaded from the database by AppID
+// before running. Passwords are never part of the snapshot, see
+// InstalledApp.MarshalJSON. A failed job waiting for a retry is pending with
+// NextRunAt set.
+type Job struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	BatchID    string         `gorm:"index" json:"batch_id"`
+	AppID      uint           `gorm:"index" json:"app_id"`
+	App        InstalledApp   `gorm:"serializer:json" json:"app"`
+	Notify     bool           `json:"notify"`
+	Trigger    RefreshTrigger `json:"trigger"`
+	Priority   JobPriority    `gorm:"index" json:"priority"`
+	State      JobState       `gorm:"index" json:"state"`
+	Attempts   int            `json:"attempts"`
+	Error      string         `json:"error,omitempty"`
+	ErrorClass string         `json:"error_class,omitempty"`
+	NextRunAt  *time.Time     `gorm:"index" json:"next_run_at"`
+	StartedAt  *time.Time     `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at"`
+	// Progress of the running or last run
+	Progress *InstallProgress `gorm:"serializer:json" json:"progress,omitempty"`
+	// CodeExpiresAt is set while the job waits for a 2FA code
+	CodeExpiresAt *time.Time `json:"code_expires_at,omitempty"`
+	// Reinstall installs the IPA like a new install instead of refreshing the
+	// installed app, e.g. to roll back to a kept version
+	Reinstall bool `json:"reinstall"`
+}
@@ -2,8 +2,9 @@ package model
 
 // Message.Type
 const (
-	MessageTypeInstall = 1
-	MessageType2FA     = 2
+	MessageTypeInstall  = 1
+	MessageType2FA      = 2
+	MessageTypeProgress = 3
 
 	MessageTypeLogin = 1
 
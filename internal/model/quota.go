@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// AppIDRegistration records that installing a bundle with an account
+// registered a new App ID. Free accounts may register a limited number of App
+// IDs per week.
+type AppIDRegistration struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+	Account          string    `gorm:"index" json:"account"`
+	BundleIdentifier string    `json:"bundle_identifier"`
+}
+
+// AccountQuota is the quota usage of an Apple ID.
+// Limits are 0 for paid accounts.
+type AccountQuota struct {
+	Account string        `json:"account"`
+	Free    bool          `json:"free"`
+	AppIDs  AppIDQuota    `json:"app_ids"`
+	Devices []DeviceQuota `json:"devices"`
+}
+
+type AppIDQuota struct {
+	Used       int        `json:"used"`
+	Limit      int        `json:"limit"`
+	Bundles    []string   `json:"bundles"`
+	NextFreeAt *time.Time `json:"next_free_at,omitempty"`
+}
+
+type DeviceQuota struct {
+	UDID   string   `json:"udid"`
+	Device string   `json:"device"`
+	Active int      `json:"active"`
+	Limit  int      `json:"limit"`
+	Apps   []string `json:"apps"`
+}
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+type AttemptOutcome string
+
+const (
+	AttemptOutcomeRunning     AttemptOutcome = "running"
+	AttemptOutcomeSucceeded   AttemptOutcome = "succeeded"
+	AttemptOutcomeFailed      AttemptOutcome = "failed"
+	AttemptOutcomeInterrupted AttemptOutcome = "interrupted"
+	AttemptOutcomeCancelled   AttemptOutcome = "cancelled"
+)
+
+// RefreshTrigger is what started a job.
+type RefreshTrigger string
+
+const (
+	RefreshTriggerCron            RefreshTrigger = "cron"
+	RefreshTriggerManual          RefreshTrigger = "manual"
+	RefreshTriggerDeviceConnected RefreshTrigger = "device_connected"
+	RefreshTriggerMCP             RefreshTrigger = "mcp"
+)
+
+// RefreshAttempt is a single run of a job. A job retried after a failure has
+// one attempt per run, each with its own log file. Interactive installs are
+// recorded without a job.
+type RefreshAttempt struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	JobID       uint           `gorm:"index" json:"job_id"`
+	AppID       uint           `gorm:"index" json:"app_id"`
+	Attempt     int            `json:"attempt"`
+	Trigger     RefreshTrigger `gorm:"index" json:"trigger"`
+	StartedAt   time.Time      `gorm:"index" json:"started_at"`
+	FinishedAt  *time.Time     `json:"finished_at"`
+	DurationMs  int64          `json:"duration_ms"`
+	Outcome     AttemptOutcome `gorm:"index" json:"outcome"`
+	ErrorClass  string         `json:"error_class,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	ProfileUUID string         `json:"profile_uuid,omitempty"`
+	NextRetryAt *time.Time     `json:"next_retry_at,omitempty"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserRole string
+
+const (
+	UserRoleAdmin  UserRole = "admin"
+	UserRoleViewer UserRole = "viewer"
+)
+
+func (r UserRole) Valid() bool {
+	return r == UserRoleAdmin || r == UserRoleViewer
+}
+
+// Allows reports whether the role grants at least the permissions of required.
+func (r UserRole) Allows(required UserRole) bool {
+	if r == UserRoleAdmin {
+		return true
+	}
+	return r == required
+}
+
+type User struct {
+	gorm.Model
+
+	Username     string     `gorm:"uniqueIndex" json:"username"`
+	PasswordHash string     `json:"-"`
+	Role         UserRole   `json:"role"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+}
+
+// UserSession is a browser login session. ID stores the sha256 of the
+// cookie value so a leaked database cannot be replayed as a session.
+type UserSession struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	IP        string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (s UserSession) IsExpired() bool {
+	return s.ExpiresAt.Before(time.Now())
+}
@@ -0,0 +1,261 @@
+// Package secret implements envelope encryption for credentials stored at
+// rest. Every value is encrypted with its own random data key, and the data
+// key is wrapped with the master key. Encrypted values look like
+//
+//	enc:v1:<key id>:<wrapped data key>:<ciphertext>
+//
+// so values written before encryption was enabled are still readable.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	prefix  = "enc:v1:"
+	KeySize = 32
+)
+
+var (
+	ErrNoKey      = errors.New("master key not loaded")
+	ErrUnknownKey = errors.New("value encrypted with unknown master key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+var keyring = &Keyring{}
+
+// Keyring holds the primary master key used for encryption and any older
+// keys that are still accepted for decryption during rotation.
+type Keyring struct {
+	mu      sync.RWMutex
+	primary *masterKey
+	keys    map[string]*masterKey
+}
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newMasterKey(key []byte) (*masterKey, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &masterKey{id: KeyID(key), aead: aead}, nil
+}
+
+// KeyID returns a short fingerprint identifying a master key.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// SetKeys replaces the keyring. primary is used for new values, older keys
+// are only used to decrypt.
+func (k *Keyring) SetKeys(primary []byte, older ...[]byte) error {
+	pk, err := newMasterKey(primary)
+	if err != nil {
+		return err
+	}
+	keys := map[string]*masterKey{pk.id: pk}
+	for _, v := range older {
+		ok, err := newMasterKey(v)
+		if err != nil {
+			return err
+		}
+		keys[ok.id] = ok
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary = pk
+	k.keys = keys
+	return nil
+}
+
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	if plain == "" || IsEncrypted(plain) {
+		return plain, nil
+	}
+
+	k.mu.RLock()
+	pk := k.primary
+	k.mu.RUnlock()
+	if pk == nil {
+		return "", ErrNoKey
+	}
+
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(pk.aead, dek)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + pk.id + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	k.mu.RLock()
+	mk, ok := k.keys[parts[0]]
+	loaded := k.primary != nil
+	k.mu.RUnlock()
+	if !loaded {
+		return "", ErrNoKey
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	dek, err := open(mk.aead, wrapped)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// GenerateKey returns a new random master key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseKey decodes a base64 or hex encoded master key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be %d bytes encoded as base64 or hex", KeySize)
+}
+
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ReadKeyFile loads a master key file. When the file does not exist and
+// generate is true, a new key is created with 0600 permissions.
+func ReadKeyFile(path string, generate bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ParseKey(string(data))
+	}
+	if !os.IsNotExist(err) || !generate {
+		return nil, err
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteKeyFile(path, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func WriteKeyFile(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(EncodeKey(key)+"\n"), 0600)
+}
+
+func SetKeys(primary []byte, older ...[]byte) error {
+	return keyring.SetKeys(primary, older...)
+}
+
+func Encrypt(plain string) (string, error) {
+	return keyring.Encrypt(plain)
+}
+
+func Decrypt(value string) (string, error) {
+	return keyring.Decrypt(value)
+}
@@ -0,0 +1,63 @@
+package secret
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	key, _ := GenerateKey()
+	k := &Keyring{}
+	if err := k.SetKeys(key); err != nil {
+		t.Fatalf("SetKeys() error = %v", err)
+	}
+
+	enc, err := k.Encrypt("hunter2")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(enc) || enc == "hunter2" {
+		t.Fatalf("Encrypt() returned unexpected value %q", enc)
+	}
+
+	plain, err := k.Decrypt(enc)
+	if err != nil || plain != "hunter2" {
+		t.Fatalf("Decrypt() = %q, %v; want %q", plain, err, "hunter2")
+	}
+
+	// legacy plaintext values pass through
+	if plain, err := k.Decrypt("legacy"); err != nil || plain != "legacy" {
+		t.Fatalf("Decrypt(plaintext) = %q, %v", plain, err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+
+	k := &Keyring{}
+	_ = k.SetKeys(oldKey)
+	enc, _ := k.Encrypt("value")
+
+	_ = k.SetKeys(newKey)
+	if _, err := k.Decrypt(enc); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt() with rotated key error = %v, want ErrUnknownKey", err)
+	}
+
+	_ = k.SetKeys(newKey, oldKey)
+	plain, err := k.Decrypt(enc)
+	if err != nil || plain != "value" {
+		t.Fatalf("Decrypt() with old key in ring = %q, %v", plain, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key, _ := GenerateKey()
+	parsed, err := ParseKey(EncodeKey(key))
+	if err != nil || string(parsed) != string(key) {
+		t.Fatalf("ParseKey(base64) = %x, %v", parsed, err)
+	}
+	if _, err := ParseKey("too short"); err == nil {
+		t.Fatal("ParseKey() should reject invalid keys")
+	}
+}
@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// String is a string column that is transparently encrypted when written to
+// the database and decrypted when read back.
+type String string
+
+func (s String) Value() (driver.Value, error) {
+	return Encrypt(string(s))
+}
+
+func (s *String) Scan(value any) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("secret.String: unsupported scan type %T", value)
+	}
+
+	plain, err := Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	*s = String(plain)
+	return nil
+}
+
+func (String) GormDataType() string {
+	return "string"
+}
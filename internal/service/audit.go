@@ -0,0 +1,71 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Target  string
+	Outcome model.AuditOutcome
+	Since   *time.Time
+	Until   *time.Time
+	Limit   int
+	Offset  int
+}
+
+// RecordAudit stores an audit entry. Failures are logged and never returned,
+// so auditing cannot break the audited action.
+func RecordAudit(entry model.AuditLog) {
+	if entry.Outcome == "" {
+		entry.Outcome = model.AuditOutcomeSuccess
+	}
+	if result := db.Store().Create(&entry); result.Error != nil {
+		log.Err(result.Error).Msgf("Save audit log failed: %s %s", entry.Action, entry.Target)
+	}
+}
+
+// QueryAuditLogs returns the newest entries matching filter and the total
+// number of matches. Action matches by prefix and Target by substring.
+func QueryAuditLogs(filter AuditFilter) ([]model.AuditLog, int64, error) {
+	query := db.Store().Model(&model.AuditLog{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action LIKE ?", filter.Action+"%")
+	}
+	if filter.Target != "" {
+		query = query.Where("target LIKE ?", "%"+filter.Target+"%")
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var logs []model.AuditLog
+	if result := query.Order("id desc").Limit(limit).Offset(filter.Offset).Find(&logs); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return logs, total, nil
+}
@@ -77,25 +77,38 @@ func SaveApp(app model.InstalledApp) (*model.InstalledApp, error) {
 	if result.Error == nil {
 		// 之前已安装过
 		app.ID = cur.ID
+		saveDir := filepath.Join(conf.Config.Server.DataDir, "ipa", fmt.Sprintf("%d", app.ID))
+		ipaPath := filepath.Join(saveDir, "app.ipa")
+		// a refresh installs from the kept app.ipa, only a new IPA is a new version
+		newIPA := app.IpaPath != "" && app.IpaPath != ipaPath
+		if newIPA {
+			archiveInstalledIPA(cur)
+		}
 
 		now := time.Now()
 		cur.IpaPath = app.IpaPath
 		cur.Icon = app.Icon
 		cur.Version = app.Version
+		cur.IpaName = app.IpaName
+		cur.OverrideBundleID = app.OverrideBundleID
+		cur.OverrideName = app.OverrideName
+		cur.OverrideVersion = app.OverrideVersion
 		cur.RefreshedDate = &now
 		cur.ExpirationDate = app.ExpirationDate
 		cur.RefreshedResult = app.RefreshedResult
 		cur.RefreshedError = app.RefreshedError
+		cur.FailureCode = app.FailureCode
 		cur.Password = app.Password
 
 		// 把 ipa/icon 移动到 ipa 保存目录
-		saveDir := filepath.Join(conf.Config.Server.DataDir, "ipa", fmt.Sprintf("%d", app.ID))
-		if cur.IpaPath != "" {
-			ipaPath := filepath.Join(saveDir, "app.ipa")
+		if newIPA {
 			if err := os.Rename(cur.IpaPath, ipaPath); err != nil {
 				log.Err(err).Msgf("Can not move to %s", ipaPath)
 			} else {
 				cur.IpaPath = ipaPath
+				if err := archiveIPA(cur.ID, ipaPath, now); err != nil {
+					log.Err(err).Msgf("Failed to keep the ipa version of %s", cur.IpaName)
+				}
 			}
 		}
 		if cur.Icon != "" {
@@ -108,14 +121,19 @@ func SaveApp(app model.InstalledApp) (*model.InstalledApp, error) {
 		}
 
 		updateData := map[string]any{
-			"ipa_path":         cur.IpaPath,
-			"icon":             cur.Icon,
-			"version":          cur.Version,
-			"refreshed_date":   cur.RefreshedDate,
-			"expiration_date":  cur.ExpirationDate,
-			"refreshed_result": cur.RefreshedResult,
-			"refreshed_error":  cur.RefreshedError,
-			"password":         cur.Password,
+			"ipa_path":           cur.IpaPath,
+			"icon":               cur.Icon,
+			"version":            cur.Version,
+			"ipa_name":           cur.IpaName,
+			"override_bundle_id": cur.OverrideBundleID,
+			"override_name":      cur.OverrideName,
+			"override_version":   cur.OverrideVersion,
+			"refreshed_date":     cur.RefreshedDate,
+			"expiration_date":    cur.ExpirationDate,
+			"refreshed_result":   cur.RefreshedResult,
+			"refreshed_error":    cur.RefreshedError,
+			"failure_code":       cur.FailureCode,
+			"password":           cur.Password,
 		}
 		if result := db.Store().Model(&cur).Updates(updateData); result.Error != nil {
 			return nil, result.Error
@@ -142,6 +160,9 @@ func SaveApp(app model.InstalledApp) (*model.InstalledApp, error) {
 				log.Err(err).Msgf("Can not move to %s", ipaPath)
 			} else {
 				app.IpaPath = ipaPath
+				if err := archiveIPA(app.ID, ipaPath, now); err != nil {
+					log.Err(err).Msgf("Failed to keep the ipa version of %s", app.IpaName)
+				}
 			}
 		}
 		if app.Icon != "" {
@@ -170,6 +191,7 @@ func UpdateAppRefreshResult(app model.InstalledApp) error {
 		"expiration_date":  app.ExpirationDate,
 		"refreshed_result": app.RefreshedResult,
 		"refreshed_error":  app.RefreshedError,
+		"failure_code":     app.FailureCode,
 	}
 	if result := db.Store().Model(&app).Updates(updateData); result.Error != nil {
 		return result.Error
@@ -178,11 +200,32 @@ func UpdateAppRefreshResult(app model.InstalledApp) error {
 	return nil
 }
 
+// UpdateAppSchedule sets the app's own refresh schedule and advance days,
+// empty values fall back to the global settings.
+func UpdateAppSchedule(id uint, refreshCron string, advanceDays int) (*model.InstalledApp, error) {
+	app, err := GetApp(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData := map[string]any{
+		"refresh_cron": refreshCron,
+		"advance_days": advanceDays,
+	}
+	if result := db.Store().Model(app).Updates(updateData); result.Error != nil {
+		return nil, result.Error
+	}
+	return app, nil
+}
+
 func DeleteApp(id uint) (bool, error) {
 	if v, err := GetApp(id); err == nil {
 		if result := db.Store().Delete(&model.InstalledApp{}, id); result.Error != nil {
 			return false, result.Error
 		}
+		if err := deleteIpaVersions(id); err != nil {
+			log.Err(err).Msgf("Failed to delete the ipa versions of %s", v.IpaName)
+		}
 		ipaDir := filepath.Dir(v.IpaPath)
 		_ = os.RemoveAll(ipaDir)
 	}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// HasExpiryAlert reports whether an alert at threshold or a closer one has
+// already been sent for the given expiration date of the app.
+func HasExpiryAlert(appID uint, expiration time.Time, threshold int) (bool, error) {
+	var alerts []model.ExpiryAlert
+	result := db.Store().Where("app_id = ? and threshold_hours <= ?", appID, threshold).Find(&alerts)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	for _, alert := range alerts {
+		// a refresh moves the expiration date, which starts over the thresholds
+		if sameExpiration(alert.ExpirationDate, expiration) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func SaveExpiryAlert(alert model.ExpiryAlert) error {
+	return db.Store().Create(&alert).Error
+}
+
+// CleanExpiryAlerts deletes the alerts of expiration dates the apps no longer
+// have, and of deleted apps. Alerts of the current expiration date are kept
+// however old they are, so an app left expired is not alerted again.
+func CleanExpiryAlerts() error {
+	var apps []model.InstalledApp
+	if result := db.Store().Select("id", "expiration_date").Find(&apps); result.Error != nil {
+		return result.Error
+	}
+	current := make(map[uint]*time.Time, len(apps))
+	for _, v := range apps {
+		current[v.ID] = v.ExpirationDate
+	}
+
+	var alerts []model.ExpiryAlert
+	if result := db.Store().Find(&alerts); result.Error != nil {
+		return result.Error
+	}
+	ids := make([]uint, 0)
+	for _, alert := range alerts {
+		if expiration := current[alert.AppID]; expiration == nil || !sameExpiration(alert.ExpirationDate, *expiration) {
+			ids = append(ids, alert.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Store().Delete(&model.ExpiryAlert{}, ids).Error
+}
+
+func sameExpiration(a, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	conf "github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestHasExpiryAlert(t *testing.T) {
+	dir := t.TempDir()
+	conf.Config = &conf.Configuration{}
+	conf.Config.Server.DataDir = dir
+	if err := db.Open(db.Config{Path: dir, FileName: "test.db"}).AutoMigrate(
+		&model.InstalledApp{},
+		&model.ExpiryAlert{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	expiration := time.Now().Add(20 * time.Hour)
+	app := model.InstalledApp{IpaName: "Fake", ExpirationDate: &expiration}
+	if err := db.Store().Create(&app).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveExpiryAlert(model.ExpiryAlert{AppID: app.ID, ExpirationDate: expiration, ThresholdHours: 24}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		expiration time.Time
+		threshold  int
+		want       bool
+	}{
+		{expiration, 24, true},
+		// a closer threshold was sent
+		{expiration, 48, true},
+		{expiration, 6, false},
+		{expiration.Add(7 * 24 * time.Hour), 24, false},
+	}
+	for _, c := range cases {
+		if got, err := HasExpiryAlert(app.ID, c.expiration, c.threshold); err != nil || got != c.want {
+			t.Errorf("HasExpiryAlert(%s, %d) = %v, %v; want %v", c.expiration, c.threshold, got, err, c.want)
+		}
+	}
+
+	if err := CleanExpiryAlerts(); err != nil {
+		t.Fatal(err)
+	}
+	if sent, _ := HasExpiryAlert(app.ID, expiration, 24); !sent {
+		t.Fatal("alert of the current expiration was cleaned")
+	}
+
+	refreshed := expiration.Add(7 * 24 * time.Hour)
+	if err := db.Store().Model(&app).Update("expiration_date", refreshed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := CleanExpiryAlerts(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Store().Model(&model.ExpiryAlert{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d alerts left, want the old expiration cleaned", count)
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	conf "github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/ipa"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"gorm.io/gorm"
+)
+
+var ErrAppInstalling = errors.New("the app is being installed, try again when it is done")
+
+// GetIpaVersions returns the IPAs kept for an app, most recently installed
+// first.
+func GetIpaVersions(appID uint) ([]model.IpaVersion, error) {
+	var versions []model.IpaVersion
+	if result := db.Store().Where("app_id = ?", appID).Order("installed_at desc, id desc").Find(&versions); result.Error != nil {
+		return nil, result.Error
+	}
+	if len(versions) > 0 {
+		versions[0].Current = true
+	}
+	return versions, nil
+}
+
+// RestoreIpaVersion makes a kept IPA the one the app is installed and
+// refreshed from. The caller queues the reinstall.
+func RestoreIpaVersion(appID uint, versionID uint) (*model.InstalledApp, error) {
+	app, err := GetApp(appID)
+	if err != nil {
+		return nil, err
+	}
+	var version model.IpaVersion
+	if result := db.Store().Where("id = ? and app_id = ?", versionID, appID).First(&version); result.Error != nil {
+		return nil, result.Error
+	}
+	if active, err := HasActiveJob(appID); err != nil {
+		return nil, err
+	} else if active {
+		return nil, ErrAppInstalling
+	}
+
+	ipaPath := filepath.Join(conf.Config.Server.DataDir, "ipa", fmt.Sprintf("%d", appID), "app.ipa")
+	tmpPath := ipaPath + ".restore"
+	if err := linkFile(version.Path, tmpPath); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, ipaPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+
+	app.IpaPath = ipaPath
+	app.Version = version.Version
+	app.ApplyOverrides()
+	updateData := map[string]any{
+		"ipa_path": app.IpaPath,
+		"version":  app.Version,
+	}
+	if result := db.Store().Model(app).Updates(updateData); result.Error != nil {
+		return nil, result.Error
+	}
+	if result := db.Store().Model(&version).Update("installed_at", time.Now()); result.Error != nil {
+		return nil, result.Error
+	}
+	return app, nil
+}
+
+// archiveIPA keeps the IPA at path in the version store of the app, marked as
+// installed at installedAt, and drops the oldest versions beyond the retention
+// count. An IPA kept before only gets its install date updated.
+func archiveIPA(appID uint, path string, installedAt time.Time) error {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+
+	var version model.IpaVersion
+	result := db.Store().Where("app_id = ? and sha256 = ?", appID, sum).First(&version)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return result.Error
+	}
+	if result.Error == nil {
+		if result := db.Store().Model(&version).Update("installed_at", installedAt); result.Error != nil {
+			return result.Error
+		}
+		return pruneIpaVersions(appID)
+	}
+
+	info, err := ipa.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	saveDir := filepath.Join(conf.Config.Server.DataDir, "ipa", fmt.Sprintf("%d", appID), "versions")
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return err
+	}
+	dst := filepath.Join(saveDir, sum[:16]+".ipa")
+	if err := linkFile(path, dst); err != nil {
+		return err
+	}
+
+	version = model.IpaVersion{
+		AppID:       appID,
+		Version:     info.Version(),
+		Build:       info.Build(),
+		SHA256:      sum,
+		Size:        size,
+		Path:        dst,
+		InstalledAt: installedAt,
+	}
+	if result := db.Store().Create(&version); result.Error != nil {
+		_ = os.Remove(dst)
+		return result.Error
+	}
+	return pruneIpaVersions(appID)
+}
+
+// archiveInstalledIPA keeps the IPA an app was installed from before the
+// version store existed, so updating it can still be rolled back.
+func archiveInstalledIPA(app model.InstalledApp) {
+	if app.IpaPath == "" {
+		return
+	}
+	var count int64
+	if result := db.Store().Model(&model.IpaVersion{}).Where("app_id = ?", app.ID).Count(&count); result.Error != nil || count > 0 {
+		return
+	}
+	installedAt := app.CreatedAt
+	if app.InstalledDate != nil {
+		installedAt = *app.InstalledDate
+	}
+	if err := archiveIPA(app.ID, app.IpaPath, installedAt); err != nil {
+		log.Err(err).Msgf("Failed to keep the installed ipa of %s", app.IpaName)
+	}
+}
+
+// pruneIpaVersions deletes the versions of an app beyond the retention count,
+// the current one is always kept.
+func pruneIpaVersions(appID uint) error {
+	keep := max(conf.Settings.Task.IpaVersions, 1)
+	var versions []model.IpaVersion
+	result := db.Store().Where("app_id = ?", appID).Order("installed_at desc, id desc").Offset(keep).Find(&versions)
+	if result.Error != nil || len(versions) == 0 {
+		return result.Error
+	}
+
+	ids := make([]uint, 0, len(versions))
+	for _, version := range versions {
+		ids = append(ids, version.ID)
+		_ = os.Remove(version.Path)
+	}
+	return db.Store().Delete(&model.IpaVersion{}, ids).Error
+}
+
+func deleteIpaVersions(appID uint) error {
+	return db.Store().Where("app_id = ?", appID).Delete(&model.IpaVersion{}).Error
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// linkFile hard links src to dst, or copies it when links are not supported.
+// dst is replaced rather than written through, it may be linked to a kept IPA.
+func linkFile(src, dst string) error {
+	_ = os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	conf "github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/model"
+	plist "howett.net/plist"
+)
+
+func setupIpaVersions(t *testing.T, keep int) string {
+	dir := t.TempDir()
+	conf.Config = &conf.Configuration{}
+	conf.Config.Server.DataDir = dir
+	conf.Settings = &conf.SettingsConfiguration{}
+	conf.Settings.Task.IpaVersions = keep
+
+	if err := db.Open(db.Config{Path: dir, FileName: "test.db"}).AutoMigrate(
+		&model.InstalledApp{},
+		&model.Job{},
+		&model.IpaVersion{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return dir
+}
+
+// writeIPA writes an IPA of com.example.fake with version and build to dir.
+func writeIPA(t *testing.T, dir, version, build string) string {
+	t.Helper()
+	path := filepath.Join(dir, "fake_"+build+".ipa")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	data, err := plist.Marshal(map[string]any{
+		"CFBundleIdentifier":         "com.example.fake",
+		"CFBundleName":               "Fake",
+		"CFBundleShortVersionString": version,
+		"CFBundleVersion":            build,
+	}, plist.XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	fw, _ := w.Create("Payload/Fake.app/Info.plist")
+	_, _ = fw.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIpaVersions(t *testing.T) {
+	dir := setupIpaVersions(t, 2)
+	install := func(version, build string) *model.InstalledApp {
+		t.Helper()
+		app, err := SaveApp(model.InstalledApp{
+			IpaName:          "Fake",
+			IpaPath:          writeIPA(t, dir, version, build),
+			BundleIdentifier: "com.example.fake",
+			UDID:             "00008110-FAKE",
+			Account:          "fake@example.com",
+			Version:          version,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return app
+	}
+
+	app := install("1.0", "100")
+	install("1.1", "110")
+	versions, err := GetIpaVersions(app.ID)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("GetIpaVersions = %+v, %v, want 2 versions", versions, err)
+	}
+	if !versions[0].Current || versions[0].Build != "110" || versions[1].Build != "100" || versions[1].SHA256 == "" {
+		t.Fatalf("versions = %+v, want 110 current then 100", versions)
+	}
+
+	// a refresh installs from the kept app.ipa, it is not a new install of it
+	refreshed, err := SaveApp(model.InstalledApp{
+		IpaName:          "Fake",
+		IpaPath:          app.IpaPath,
+		BundleIdentifier: "com.example.fake",
+		UDID:             "00008110-FAKE",
+		Account:          "fake@example.com",
+		Version:          "1.1",
+	})
+	if err != nil || refreshed.IpaPath != app.IpaPath {
+		t.Fatalf("SaveApp = %+v, %v, want app.ipa kept", refreshed, err)
+	}
+	if got, _ := GetIpaVersions(app.ID); len(got) != 2 || !got[0].InstalledAt.Equal(versions[0].InstalledAt) {
+		t.Fatalf("versions = %+v, want them unchanged by a refresh", got)
+	}
+
+	// reinstalling a kept ipa does not add a version
+	install("1.1", "110")
+	if versions, _ = GetIpaVersions(app.ID); len(versions) != 2 {
+		t.Fatalf("versions = %+v, want 2 after reinstalling the same ipa", versions)
+	}
+
+	install("1.2", "120")
+	versions, _ = GetIpaVersions(app.ID)
+	if len(versions) != 2 || versions[0].Build != "120" || versions[1].Build != "110" {
+		t.Fatalf("versions = %+v, want oldest pruned", versions)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "ipa", "*", "versions", "*.ipa"))
+	if len(files) != 2 {
+		t.Fatalf("kept files = %v, want 2", files)
+	}
+
+	restored, err := RestoreIpaVersion(app.ID, versions[1].ID)
+	if err != nil {
+		t.Fatalf("RestoreIpaVersion = %v", err)
+	}
+	if restored.Version != "1.1" {
+		t.Fatalf("restored version = %s, want 1.1", restored.Version)
+	}
+	sum, _, err := fileSHA256(restored.IpaPath)
+	if err != nil || sum != versions[1].SHA256 {
+		t.Fatalf("app.ipa sha256 = %s, %v, want %s", sum, err, versions[1].SHA256)
+	}
+	if versions, _ = GetIpaVersions(app.ID); !versions[0].Current || versions[0].Build != "110" {
+		t.Fatalf("versions = %+v, want restored 110 current", versions)
+	}
+	// the newer version is still kept to roll forward
+	if _, err := os.Stat(versions[1].Path); err != nil {
+		t.Fatalf("newer version file: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"os"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"gorm.io/gorm"
+)
+
+// EnqueueJobs stores new pending jobs in a single transaction.
+func EnqueueJobs(jobs []model.Job) ([]model.Job, error) {
+	if len(jobs) == 0 {
+		return jobs, nil
+	}
+	for i := range jobs {
+		jobs[i].State = model.JobStatePending
+	}
+	if result := db.Store().Create(&jobs); result.Error != nil {
+		return nil, result.Error
+	}
+	return jobs, nil
+}
+
+// HasActiveJob reports whether the app already has a pending or running job.
+func HasActiveJob(appID uint) (bool, error) {
+	var count int64
+	result := db.Store().Model(&model.Job{}).
+		Where("app_id = ? and state in ?", appID, model.ActiveJobStates).
+		Count(&count)
+	return count > 0, result.Error
+}
+
+func GetActiveJobs() ([]model.Job, error) {
+	var jobs []model.Job
+	result := db.Store().
+		Where("state in ?", model.ActiveJobStates).
+		Order("id asc").
+		Find(&jobs)
+	return jobs, result.Error
+}
+
+// GetJobList returns the newest jobs, optionally filtered by state.
+func GetJobList(state model.JobState, limit int) ([]model.Job, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := db.Store().Order("id desc").Limit(limit)
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	var jobs []model.Job
+	result := query.Find(&jobs)
+	return jobs, result.Error
+}
+
+func GetBatchJobs(batchID string) ([]model.Job, error) {
+	var jobs []model.Job
+	result := db.Store().Where("batch_id = ?", batchID).Order("id asc").Find(&jobs)
+	return jobs, result.Error
+}
+
+// ResetRunningJobs puts jobs interrupted by a restart back to pending.
+func ResetRunningJobs() (int64, error) {
+	now := time.Now()
+	if result := db.Store().Model(&model.RefreshAttempt{}).
+		Where("outcome = ?", model.AttemptOutcomeRunning).
+		Updates(map[string]any{"outcome": model.AttemptOutcomeInterrupted, "finished_at": now}); result.Error != nil {
+		return 0, result.Error
+	}
+
+	result := db.Store().Model(&model.Job{}).
+		Where("state in ?", []model.JobState{model.JobStateRunning, model.JobStateAwaiting2FA}).
+		Updates(map[string]any{"state": model.JobStatePending, "started_at": nil, "code_expires_at": nil})
+	return result.RowsAffected, result.Error
+}
+
+// GetPendingJobs returns the pending jobs ready to run, oldest first.
+func GetPendingJobs() ([]model.Job, error) {
+	var jobs []model.Job
+	result := db.Store().
+		Where("state = ? and (next_run_at is null or next_run_at <= ?)", model.JobStatePending, time.Now()).
+		Order("id asc").
+		Find(&jobs)
+	return jobs, result.Error
+}
+
+// NextRetryTime returns when the earliest delayed retry becomes ready.
+func NextRetryTime() (*time.Time, error) {
+	var job model.Job
+	result := db.Store().
+		Where("state = ? and next_run_at > ?", model.JobStatePending, time.Now()).
+		Order("next_run_at asc").
+		Limit(1).
+		Find(&job)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return job.NextRunAt, nil
+}
+
+// ExpediteJob lets a delayed retry of the app run right away, and raises the
+// priority of its pending job to at least priority.
+func ExpediteJob(appID uint, priority model.JobPriority) error {
+	result := db.Store().Model(&model.Job{}).
+		Where("app_id = ? and state = ?", appID, model.JobStatePending).
+		Updates(map[string]any{"next_run_at": nil, "priority": gorm.Expr("max(priority, ?)", priority)})
+	return result.Error
+}
+
+// ClaimJob marks a pending job as running. It returns false when the job is
+// no longer pending, e.g. claimed by another worker.
+func ClaimJob(job *model.Job) (bool, error) {
+	now := time.Now()
+	result := db.Store().Model(&model.Job{}).
+		Where("id = ? and state = ?", job.ID, model.JobStatePending).
+		Updates(map[string]any{"state": model.JobStateRunning, "started_at": now, "attempts": job.Attempts + 1})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	job.State = model.JobStateRunning
+	job.StartedAt = &now
+	job.Attempts++
+	return true, nil
+}
+
+// FinishJob records the final state of a job.
+func FinishJob(id uint, state model.JobState, errClass string, errMsg string) error {
+	now := time.Now()
+	result := db.Store().Model(&model.Job{}).Where("id = ?", id).
+		Updates(map[string]any{"state": state, "error_class": errClass, "error": errMsg, "finished_at": now, "next_run_at": nil, "code_expires_at": nil})
+	return result.Error
+}
+
+// CancelJob cancels a job that has not started yet. It returns false when the
+// job is no longer pending.
+func CancelJob(id uint) (bool, error) {
+	now := time.Now()
+	result := db.Store().Model(&model.Job{}).
+		Where("id = ? and state = ?", id, model.JobStatePending).
+		Updates(map[string]any{"state": model.JobStateCancelled, "finished_at": now, "next_run_at": nil})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RetryJob puts a failed job back to pending, to run again at nextRunAt.
+func RetryJob(id uint, nextRunAt time.Time, errClass string, errMsg string) error {
+	result := db.Store().Model(&model.Job{}).Where("id = ?", id).
+		Updates(map[string]any{"state": model.JobStatePending, "error_class": errClass, "error": errMsg, "next_run_at": nextRunAt, "code_expires_at": nil})
+	return result.Error
+}
+
+// AwaitJobCode pauses a running job until a 2FA code is entered, or until
+// expiresAt.
+func AwaitJobCode(id uint, expiresAt time.Time) error {
+	result := db.Store().Model(&model.Job{}).
+		Where("id = ? and state in ?", id, []model.JobState{model.JobStateRunning, model.JobStateAwaiting2FA}).
+		Updates(map[string]any{"state": model.JobStateAwaiting2FA, "code_expires_at": expiresAt})
+	return result.Error
+}
+
+// ResumeJob puts a job back to running once its 2FA code has been entered.
+func ResumeJob(id uint) error {
+	result := db.Store().Model(&model.Job{}).
+		Where("id = ? and state = ?", id, model.JobStateAwaiting2FA).
+		Updates(map[string]any{"state": model.JobStateRunning, "code_expires_at": nil})
+	return result.Error
+}
+
+// UpdateJobProgress saves the install phase of a running job.
+func UpdateJobProgress(id uint, progress model.InstallProgress) error {
+	return db.Store().Model(&model.Job{}).Where("id = ?", id).Updates(&model.Job{Progress: &progress}).Error
+}
+
+func GetJob(id uint) (*model.Job, error) {
+	var job model.Job
+	if result := db.Store().First(&job, id); result.Error != nil {
+		return nil, result.Error
+	}
+	return &job, nil
+}
+
+// StartAttempt records the start of a job run.
+func StartAttempt(job model.Job) (*model.RefreshAttempt, error) {
+	attempt := model.RefreshAttempt{
+		JobID:     job.ID,
+		AppID:     job.AppID,
+		Attempt:   job.Attempts,
+		Trigger:   job.Trigger,
+		StartedAt: time.Now(),
+		Outcome:   model.AttemptOutcomeRunning,
+	}
+	if result := db.Store().Create(&attempt); result.Error != nil {
+		return nil, result.Error
+	}
+	return &attempt, nil
+}
+
+// FinishAttempt records the end of a job run.
+func FinishAttempt(attempt *model.RefreshAttempt) error {
+	now := time.Now()
+	attempt.FinishedAt = &now
+	attempt.DurationMs = now.Sub(attempt.StartedAt).Milliseconds()
+	result := db.Store().Model(attempt).Updates(map[string]any{
+		"app_id":        attempt.AppID,
+		"finished_at":   attempt.FinishedAt,
+		"duration_ms":   attempt.DurationMs,
+		"outcome":       attempt.Outcome,
+		"error_class":   attempt.ErrorClass,
+		"error":         attempt.Error,
+		"profile_uuid":  attempt.ProfileUUID,
+		"next_retry_at": attempt.NextRetryAt,
+	})
+	return result.Error
+}
+
+// SaveAttempt records a finished attempt that did not run from a job.
+func SaveAttempt(attempt *model.RefreshAttempt) error {
+	if attempt.FinishedAt != nil {
+		attempt.DurationMs = attempt.FinishedAt.Sub(attempt.StartedAt).Milliseconds()
+	}
+	return db.Store().Create(attempt).Error
+}
+
+func GetAttempt(id uint) (*model.RefreshAttempt, error) {
+	var attempt model.RefreshAttempt
+	if result := db.Store().First(&attempt, id); result.Error != nil {
+		return nil, result.Error
+	}
+	return &attempt, nil
+}
+
+// GetAppAttempts returns the refresh history of an app, newest first.
+func GetAppAttempts(appID uint, limit int) ([]model.RefreshAttempt, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var attempts []model.RefreshAttempt
+	result := db.Store().Where("app_id = ?", appID).Order("id desc").Limit(limit).Find(&attempts)
+	return attempts, result.Error
+}
+
+func GetJobAttempts(jobID uint) ([]model.RefreshAttempt, error) {
+	var attempts []model.RefreshAttempt
+	result := db.Store().Where("job_id = ?", jobID).Order("id asc").Find(&attempts)
+	return attempts, result.Error
+}
+
+// AverageAttemptDuration returns the average duration of the last n
+// successful attempts, or 0 when there are none.
+func AverageAttemptDuration(n int) (time.Duration, error) {
+	var durations []int64
+	result := db.Store().Model(&model.RefreshAttempt{}).
+		Where("outcome = ? and duration_ms > 0", model.AttemptOutcomeSucceeded).
+		Order("id desc").Limit(n).
+		Pluck("duration_ms", &durations)
+	if result.Error != nil || len(durations) == 0 {
+		return 0, result.Error
+	}
+	var total int64
+	for _, v := range durations {
+		total += v
+	}
+	return time.Duration(total/int64(len(durations))) * time.Millisecond, nil
+}
+
+// GetAttemptsSince returns the attempts started after since, oldest first.
+func GetAttemptsSince(since time.Time) ([]model.RefreshAttempt, error) {
+	var attempts []model.RefreshAttempt
+	result := db.Store().Where("started_at >= ?", since).Order("id asc").Find(&attempts)
+	return attempts, result.Error
+}
+
+// CleanFinishedJobs deletes finished jobs older than before. Their attempts
+// are kept as refresh history, see CleanRefreshAttempts.
+func CleanFinishedJobs(before time.Time) error {
+	result := db.Store().
+		Where("state not in ? and finished_at < ?", model.ActiveJobStates, before).
+		Delete(&model.Job{})
+	return result.Error
+}
+
+// CleanRefreshAttempts deletes attempts and their log files older than before.
+func CleanRefreshAttempts(before time.Time) error {
+	var attempts []model.RefreshAttempt
+	result := db.Store().Select("id").
+		Where("outcome <> ? and started_at < ?", model.AttemptOutcomeRunning, before).
+		Find(&attempts)
+	if result.Error != nil || len(attempts) == 0 {
+		return result.Error
+	}
+
+	ids := make([]uint, 0, len(attempts))
+	for _, attempt := range attempts {
+		ids = append(ids, attempt.ID)
+		_ = os.Remove(manager.AttemptLogPath(attempt.ID))
+	}
+	return db.Store().Delete(&model.RefreshAttempt{}, ids).Error
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	conf "github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func setupAttempts(t *testing.T) {
+	dir := t.TempDir()
+	conf.Config = &conf.Configuration{}
+	conf.Config.Server.DataDir = dir
+	conf.Settings = &conf.SettingsConfiguration{}
+
+	if err := db.Open(db.Config{Path: dir, FileName: "test.db"}).AutoMigrate(
+		&model.InstalledApp{},
+		&model.RefreshAttempt{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+}
+
+func writeAttemptLog(t *testing.T, id uint) string {
+	t.Helper()
+	path := manager.AttemptLogPath(id)
+	if err := os.MkdirAll(conf.AttemptLogDir(conf.Config), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetAppAttempts(t *testing.T) {
+	setupAttempts(t)
+	now := time.Now()
+	for i := range 5 {
+		appID := uint(1)
+		if i%2 == 1 {
+			appID = 2
+		}
+		attempt := model.RefreshAttempt{AppID: appID, Attempt: 1, StartedAt: now, Outcome: model.AttemptOutcomeSucceeded}
+		if err := SaveAttempt(&attempt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attempts, err := GetAppAttempts(1, 0)
+	if err != nil || len(attempts) != 3 {
+		t.Fatalf("GetAppAttempts = %+v, %v, want 3 attempts", attempts, err)
+	}
+	if attempts[0].ID != 5 || attempts[2].ID != 1 {
+		t.Fatalf("attempts = %+v, want newest first", attempts)
+	}
+	if attempts, _ = GetAppAttempts(1, 2); len(attempts) != 2 || attempts[0].ID != 5 {
+		t.Fatalf("attempts = %+v, want the 2 newest", attempts)
+	}
+	if attempts, _ = GetAppAttempts(3, 0); len(attempts) != 0 {
+		t.Fatalf("attempts = %+v, want none for another app", attempts)
+	}
+}
+
+func TestCleanRefreshAttempts(t *testing.T) {
+	setupAttempts(t)
+	now := time.Now()
+	old := model.RefreshAttempt{AppID: 1, StartedAt: now.AddDate(0, 0, -40), Outcome: model.AttemptOutcomeFailed}
+	running := model.RefreshAttempt{AppID: 1, StartedAt: now.AddDate(0, 0, -40), Outcome: model.AttemptOutcomeRunning}
+	recent := model.RefreshAttempt{AppID: 1, StartedAt: now, Outcome: model.AttemptOutcomeSucceeded}
+	for _, attempt := range []*model.RefreshAttempt{&old, &running, &recent} {
+		if err := SaveAttempt(attempt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldLog := writeAttemptLog(t, old.ID)
+	recentLog := writeAttemptLog(t, recent.ID)
+
+	if err := CleanRefreshAttempts(now.AddDate(0, 0, -30)); err != nil {
+		t.Fatalf("CleanRefreshAttempts = %v", err)
+	}
+	if _, err := GetAttempt(old.ID); err == nil {
+		t.Fatal("old attempt was kept")
+	}
+	if _, err := os.Stat(oldLog); !os.IsNotExist(err) {
+		t.Fatalf("old attempt log: %v, want removed", err)
+	}
+	if _, err := GetAttempt(running.ID); err != nil {
+		t.Fatalf("running attempt: %v, want kept", err)
+	}
+	if _, err := GetAttempt(recent.ID); err != nil {
+		t.Fatalf("recent attempt: %v, want kept", err)
+	}
+	if _, err := os.Stat(recentLog); err != nil {
+		t.Fatalf("recent attempt log: %v, want kept", err)
+	}
+}
+
+func TestSaveInstallAttemptFailed(t *testing.T) {
+	setupAttempts(t)
+	app := model.InstalledApp{UDID: "00008110-FAKE", BundleIdentifier: "com.example.fake", Account: "fake@example.com"}
+	if err := db.Store().Create(&app).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	installMgr := manager.NewInteractiveInstallManager()
+	appID := installedAppID(model.InstalledApp{UDID: app.UDID, BundleIdentifier: app.BundleIdentifier, Account: app.Account})
+	if appID != app.ID {
+		t.Fatalf("installedAppID = %d, want %d", appID, app.ID)
+	}
+	saveInstallAttempt(installMgr, appID, time.Now(), errors.New("ERROR: No space left on device"))
+
+	attempts, err := GetAppAttempts(app.ID, 0)
+	if err != nil || len(attempts) != 1 {
+		t.Fatalf("GetAppAttempts = %+v, %v, want 1 attempt", attempts, err)
+	}
+	attempt := attempts[0]
+	if attempt.Outcome != model.AttemptOutcomeFailed || attempt.ErrorClass != string(model.FailureDeviceDiskFull) || attempt.Error == "" {
+		t.Fatalf("attempt = %+v, want failed with device_disk_full", attempt)
+	}
+	if _, err := os.Stat(manager.AttemptLogPath(attempt.ID)); err != nil {
+		t.Fatalf("attempt log: %v", err)
+	}
+
+	// failed installs of a new app are not recorded
+	if id := installedAppID(model.InstalledApp{UDID: app.UDID, BundleIdentifier: "com.example.other", Account: app.Account}); id != 0 {
+		t.Fatalf("installedAppID = %d, want 0 for a new app", id)
+	}
+}
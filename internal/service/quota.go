@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+// Apple limits for free (personal team) accounts.
+const (
+	FreeAppIDLimit     = 10
+	FreeAppIDWindow    = 7 * 24 * time.Hour
+	FreeActiveAppLimit = 3
+)
+
+const (
+	QuotaAppIDs     = "app_ids"
+	QuotaActiveApps = "active_apps"
+)
+
+// QuotaError is returned when an install would exceed a free account quota.
+// RetryAt is set when the install can run once older App IDs expire.
+type QuotaError struct {
+	Kind    string
+	Account string
+	Used    int
+	Limit   int
+	RetryAt *time.Time
+}
+
+func (e *QuotaError) Error() string {
+	if e.Kind == QuotaActiveApps {
+		return fmt.Sprintf("free account %s already has %d of %d active apps on this device, installing another one would disable an installed app", e.Account, e.Used, e.Limit)
+	}
+	msg := fmt.Sprintf("free account %s has registered %d of %d App IDs in the last 7 days", e.Account, e.Used, e.Limit)
+	if e.RetryAt != nil {
+		msg += fmt.Sprintf(", next one available at %s", e.RetryAt.Local().Format(time.DateTime))
+	}
+	return msg
+}
+
+// IsFreeAccount reports whether the quota of free accounts applies to the
+// account, i.e. it is not listed as a paid developer account in the settings.
+func IsFreeAccount(account string) bool {
+	for _, v := range strings.Split(app.Settings.Task.PaidAccounts, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), account) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckQuota returns a *QuotaError when installing v would exceed the quota
+// of a free account. An empty BundleIdentifier counts as a new App ID.
+func CheckQuota(v model.InstalledApp, now time.Time) error {
+	if v.Account == "" || !IsFreeAccount(v.Account) {
+		return nil
+	}
+
+	regs, err := getAppIDRegistrations(v.Account, now.Add(-FreeAppIDWindow))
+	if err != nil {
+		return err
+	}
+	var apps []model.InstalledApp
+	if v.ID == 0 {
+		if result := db.Store().Where("enabled = ? and account = ? and udid = ?", true, v.Account, v.UDID).Find(&apps); result.Error != nil {
+			return result.Error
+		}
+	}
+	if quotaErr := checkQuota(v, regs, apps, now); quotaErr != nil {
+		return quotaErr
+	}
+	return nil
+}
+
+// checkQuota checks v against the App IDs registered by its account within
+// the window and, for new installs, the other apps of the account on the
+// device.
+func checkQuota(v model.InstalledApp, regs []model.AppIDRegistration, apps []model.InstalledApp, now time.Time) *QuotaError {
+	if v.ID == 0 {
+		active := activeApps(apps, v, now)
+		if len(active) >= FreeActiveAppLimit {
+			return &QuotaError{Kind: QuotaActiveApps, Account: v.Account, Used: len(active), Limit: FreeActiveAppLimit}
+		}
+	}
+
+	for _, reg := range regs {
+		if v.BundleIdentifier != "" && reg.BundleIdentifier == v.BundleIdentifier {
+			// the App ID of the bundle is still valid
+			return nil
+		}
+	}
+	if len(regs) < FreeAppIDLimit {
+		return nil
+	}
+	// regs are oldest first, the oldest one frees a slot when it expires
+	retryAt := regs[len(regs)-FreeAppIDLimit].CreatedAt.Add(FreeAppIDWindow)
+	return &QuotaError{Kind: QuotaAppIDs, Account: v.Account, Used: len(regs), Limit: FreeAppIDLimit, RetryAt: &retryAt}
+}
+
+// activeApps returns the names of the unexpired apps among apps, apart from
+// a previous install of the same bundle that v replaces.
+func activeApps(apps []model.InstalledApp, v model.InstalledApp, now time.Time) []string {
+	names := make([]string, 0)
+	for _, a := range apps {
+		if a.ID == v.ID || (v.BundleIdentifier != "" && a.BundleIdentifier == v.BundleIdentifier) {
+			continue
+		}
+		if a.ExpirationDate != nil && a.ExpirationDate.After(now) {
+			names = append(names, a.IpaName)
+		}
+	}
+	return names
+}
+
+// RecordAppIDRegistration notes a successful install of bundleID with the
+// account, counting a new App ID unless one was registered within the window.
+func RecordAppIDRegistration(account string, bundleID string, now time.Time) error {
+	if account == "" || bundleID == "" {
+		return nil
+	}
+	var count int64
+	result := db.Store().Model(&model.AppIDRegistration{}).
+		Where("account = ? and bundle_identifier = ? and created_at >= ?", account, bundleID, now.Add(-FreeAppIDWindow)).
+		Count(&count)
+	if result.Error != nil || count > 0 {
+		return result.Error
+	}
+	return db.Store().Create(&model.AppIDRegistration{CreatedAt: now, Account: account, BundleIdentifier: bundleID}).Error
+}
+
+func getAppIDRegistrations(account string, since time.Time) ([]model.AppIDRegistration, error) {
+	var regs []model.AppIDRegistration
+	result := db.Store().Where("account = ? and created_at >= ?", account, since).Order("created_at asc").Find(&regs)
+	return regs, result.Error
+}
+
+// GetQuotaList returns the quota usage of every account known from the
+// signed in accounts and the installed apps.
+func GetQuotaList(accounts []string, now time.Time) ([]model.AccountQuota, error) {
+	apps, err := GetEnableAppList()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range accounts {
+		seen[v] = true
+	}
+	for _, v := range apps {
+		if v.Account != "" && !seen[v.Account] {
+			seen[v.Account] = true
+			accounts = append(accounts, v.Account)
+		}
+	}
+	sort.Strings(accounts)
+
+	quotas := make([]model.AccountQuota, 0, len(accounts))
+	for _, account := range accounts {
+		regs, err := getAppIDRegistrations(account, now.Add(-FreeAppIDWindow))
+		if err != nil {
+			return nil, err
+		}
+
+		q := model.AccountQuota{Account: account, Free: IsFreeAccount(account), Devices: make([]model.DeviceQuota, 0)}
+		q.AppIDs.Used = len(regs)
+		q.AppIDs.Bundles = make([]string, 0, len(regs))
+		for _, reg := range regs {
+			q.AppIDs.Bundles = append(q.AppIDs.Bundles, reg.BundleIdentifier)
+		}
+		if q.Free {
+			q.AppIDs.Limit = FreeAppIDLimit
+			if len(regs) >= FreeAppIDLimit {
+				next := regs[len(regs)-FreeAppIDLimit].CreatedAt.Add(FreeAppIDWindow)
+				q.AppIDs.NextFreeAt = &next
+			}
+		}
+
+		byDevice := make(map[string][]model.InstalledApp)
+		order := make([]string, 0)
+		for _, v := range apps {
+			if v.Account != account {
+				continue
+			}
+			if _, ok := byDevice[v.UDID]; !ok {
+				order = append(order, v.UDID)
+			}
+			byDevice[v.UDID] = append(byDevice[v.UDID], v)
+		}
+		for _, udid := range order {
+			active := activeApps(byDevice[udid], model.InstalledApp{}, now)
+			d := model.DeviceQuota{UDID: udid, Device: byDevice[udid][0].Device, Active: len(active), Apps: active}
+			if q.Free {
+				d.Limit = FreeActiveAppLimit
+			}
+			q.Devices = append(q.Devices, d)
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, nil
+}
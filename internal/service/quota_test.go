@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestCheckQuota(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	expiration := now.AddDate(0, 0, 5)
+	expired := now.AddDate(0, 0, -1)
+	apps := []model.InstalledApp{
+		{IpaName: "A", BundleIdentifier: "com.a", ExpirationDate: &expiration},
+		{IpaName: "B", BundleIdentifier: "com.b", ExpirationDate: &expiration},
+		{IpaName: "C", BundleIdentifier: "com.c", ExpirationDate: &expired},
+	}
+	for i := range apps {
+		apps[i].ID = uint(i + 1)
+	}
+	v := model.InstalledApp{Account: "free@example.com", BundleIdentifier: "com.d"}
+	if err := checkQuota(v, nil, apps, now); err != nil {
+		t.Fatalf("checkQuota = %v, want nil with 2 active apps", err)
+	}
+
+	apps[2].ExpirationDate = &expiration
+	err := checkQuota(v, nil, apps, now)
+	if err == nil || err.Kind != QuotaActiveApps || err.Used != 3 {
+		t.Fatalf("checkQuota = %v, want active apps quota error", err)
+	}
+	// reinstalling an installed bundle replaces it
+	v.BundleIdentifier = "com.c"
+	if err := checkQuota(v, nil, apps, now); err != nil {
+		t.Fatalf("checkQuota = %v, want nil when replacing an app", err)
+	}
+
+	regs := make([]model.AppIDRegistration, 0, FreeAppIDLimit)
+	for i := range FreeAppIDLimit {
+		regs = append(regs, model.AppIDRegistration{CreatedAt: now.AddDate(0, 0, -6).Add(time.Duration(i) * time.Hour), BundleIdentifier: "com.x" + string(rune('0'+i))})
+	}
+	// refresh of an app whose App ID is still registered
+	v = model.InstalledApp{Account: "free@example.com", BundleIdentifier: "com.x3"}
+	v.ID = 1
+	if err := checkQuota(v, regs, apps, now); err != nil {
+		t.Fatalf("checkQuota = %v, want nil for a registered bundle", err)
+	}
+
+	v.BundleIdentifier = "com.a"
+	err = checkQuota(v, regs, apps, now)
+	if err == nil || err.Kind != QuotaAppIDs || err.RetryAt == nil {
+		t.Fatalf("checkQuota = %v, want App ID quota error", err)
+	}
+	if want := regs[0].CreatedAt.Add(FreeAppIDWindow); !err.RetryAt.Equal(want) {
+		t.Fatalf("RetryAt = %v, want %v", err.RetryAt, want)
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/utils"
+)
+
+const apiTokenPrefix = "atvl_"
+
+var (
+	ErrTokenInvalid = errors.New("invalid api token")
+	ErrTokenExpired = errors.New("api token expired")
+	ErrTokenRevoked = errors.New("api token revoked")
+)
+
+// CreateAPIToken issues a new token for the user and returns the plaintext
+// value, which is not stored and cannot be recovered later.
+func CreateAPIToken(user model.User, name string, scopes []string, expiresAt *time.Time) (string, *model.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !model.ValidScope(scope) {
+			return "", nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+		if !user.Role.Allows(model.ScopeRole(scope)) {
+			return "", nil, fmt.Errorf("role %s cannot grant scope: %s", user.Role, scope)
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return "", nil, errors.New("expiration must be in the future")
+	}
+
+	raw := apiTokenPrefix + utils.RandomToken(32)
+	token := model.APIToken{
+		Name:      name,
+		TokenHash: utils.Sha256(raw),
+		Prefix:    raw[:len(apiTokenPrefix)+6],
+		Scopes:    scopes,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	}
+	if result := db.Store().Create(&token); result.Error != nil {
+		return "", nil, result.Error
+	}
+
+	return raw, &token, nil
+}
+
+// GetAPITokenList returns the tokens of a user, or of every user when userID is 0.
+func GetAPITokenList(userID uint) ([]model.APIToken, error) {
+	var tokens []model.APIToken
+	query := db.Store().Order("created_at desc")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if result := query.Find(&tokens); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return tokens, nil
+}
+
+func GetAPIToken(id uint) (*model.APIToken, error) {
+	var token model.APIToken
+	if result := db.Store().Where("id = ?", id).First(&token); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &token, nil
+}
+
+func RevokeAPIToken(id uint) error {
+	token, err := GetAPIToken(id)
+	if err != nil {
+		return err
+	}
+	if token.IsRevoked() {
+		return nil
+	}
+
+	return db.Store().Model(token).Update("revoked_at", time.Now()).Error
+}
+
+// AuthenticateToken resolves a plaintext bearer token to the token record and
+// its owner, and records the last use.
+func AuthenticateToken(raw string) (*model.APIToken, *model.User, error) {
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, nil, ErrTokenInvalid
+	}
+
+	var token model.APIToken
+	if result := db.Store().Where("token_hash = ?", utils.Sha256(raw)).First(&token); result.Error != nil {
+		return nil, nil, ErrTokenInvalid
+	}
+	if token.IsRevoked() {
+		return nil, nil, ErrTokenRevoked
+	}
+	if token.IsExpired() {
+		return nil, nil, ErrTokenExpired
+	}
+
+	user, err := GetUser(token.UserID)
+	if err != nil {
+		return nil, nil, ErrTokenInvalid
+	}
+
+	// avoid a write on every request
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		token.LastUsedAt = &now
+		_ = db.Store().Model(&token).Update("last_used_at", now).Error
+	}
+
+	return &token, user, nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	conf "github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrSessionExpired     = errors.New("session expired")
+	ErrLastAdmin          = errors.New("cannot remove the last admin user")
+)
+
+func GetUserList() ([]model.User, error) {
+	var users []model.User
+	if result := db.Store().Order("created_at asc").Find(&users); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return users, nil
+}
+
+func GetUser(id uint) (*model.User, error) {
+	var user model.User
+	if result := db.Store().Where("id = ?", id).First(&user); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+func CreateUser(username, password string, role model.UserRole) (*model.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, errors.New("username and password are required")
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	if result := db.Store().Create(&user); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// UpdateUser changes the role and, when password is not empty, the password
+// of a user. Existing sessions are revoked when the password changes.
+func UpdateUser(id uint, password string, role model.UserRole) (*model.User, error) {
+	user, err := GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData := map[string]any{}
+	if role != "" && role != user.Role {
+		if !role.Valid() {
+			return nil, fmt.Errorf("invalid role: %s", role)
+		}
+		if user.Role == model.UserRoleAdmin {
+			if err := ensureOtherAdmin(user.ID); err != nil {
+				return nil, err
+			}
+		}
+		updateData["role"] = role
+	}
+	if password != "" {
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		updateData["password_hash"] = hash
+	}
+	if len(updateData) == 0 {
+		return user, nil
+	}
+
+	if result := db.Store().Model(user).Updates(updateData); result.Error != nil {
+		return nil, result.Error
+	}
+	if password != "" {
+		_ = deleteUserSessions(user.ID)
+	}
+
+	return GetUser(id)
+}
+
+func DeleteUser(id uint) error {
+	user, err := GetUser(id)
+	if err != nil {
+		return err
+	}
+	if user.Role == model.UserRoleAdmin {
+		if err := ensureOtherAdmin(user.ID); err != nil {
+			return err
+		}
+	}
+
+	if result := db.Store().Unscoped().Delete(&model.User{}, id); result.Error != nil {
+		return result.Error
+	}
+	if result := db.Store().Model(&model.APIToken{}).Where("user_id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now()); result.Error != nil {
+		return result.Error
+	}
+	return deleteUserSessions(id)
+}
+
+func ensureOtherAdmin(id uint) error {
+	var count int64
+	if result := db.Store().Model(&model.User{}).Where("role = ? AND id <> ?", model.UserRoleAdmin, id).Count(&count); result.Error != nil {
+		return result.Error
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// EnsureAdminUser creates the initial admin account when authentication is
+// enabled and the user table is empty. Without a configured password a random
+// one is generated and printed once to the log.
+func EnsureAdminUser() error {
+	if !conf.Config.Server.Auth.Enabled {
+		return nil
+	}
+
+	var count int64
+	if result := db.Store().Model(&model.User{}).Count(&count); result.Error != nil {
+		return result.Error
+	}
+	if count > 0 {
+		return nil
+	}
+
+	username := conf.Config.Server.Auth.AdminUsername
+	if username == "" {
+		username = "admin"
+	}
+	password := conf.Config.Server.Auth.AdminPassword
+	generated := password == ""
+	if generated {
+		password = utils.RandomToken(12)
+	}
+
+	if _, err := CreateUser(username, password, model.UserRoleAdmin); err != nil {
+		return err
+	}
+
+	if generated {
+		log.ColorWarnf("Created initial admin user \"%s\" with password: %s\n", username, password)
+	} else {
+		log.Infof("Created initial admin user: %s", username)
+	}
+	return nil
+}
+
+// Login verifies the credentials and returns the user together with a new
+// session token to be stored in the client cookie.
+func Login(username, password, ip string) (*model.User, string, error) {
+	var user model.User
+	result := db.Store().Where("username = ?", strings.TrimSpace(username)).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, "", ErrInvalidCredentials
+		}
+		return nil, "", result.Error
+	}
+	if !utils.CheckPassword(user.PasswordHash, password) {
+		return nil, "", ErrInvalidCredentials
+	}
+
+	token := utils.RandomToken(32)
+	now := time.Now()
+	session := model.UserSession{
+		ID:        utils.Sha256(token),
+		UserID:    user.ID,
+		IP:        ip,
+		ExpiresAt: now.Add(SessionTTL()),
+	}
+	if result := db.Store().Create(&session); result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	user.LastLoginAt = &now
+	_ = db.Store().Model(&user).Update("last_login_at", now).Error
+
+	return &user, token, nil
+}
+
+func Logout(token string) error {
+	if token == "" {
+		return nil
+	}
+	return db.Store().Where("id = ?", utils.Sha256(token)).Delete(&model.UserSession{}).Error
+}
+
+// GetSessionUser resolves the user owning the session token.
+func GetSessionUser(token string) (*model.User, error) {
+	var session model.UserSession
+	if result := db.Store().Where("id = ?", utils.Sha256(token)).First(&session); result.Error != nil {
+		return nil, result.Error
+	}
+	if session.IsExpired() {
+		_ = db.Store().Delete(&session).Error
+		return nil, ErrSessionExpired
+	}
+
+	return GetUser(session.UserID)
+}
+
+func SessionTTL() time.Duration {
+	hours := conf.Config.Server.Auth.SessionTTL
+	if hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func deleteUserSessions(userID uint) error {
+	return db.Store().Where("user_id = ?", userID).Delete(&model.UserSession{}).Error
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bitxeno/atvloadly/internal/db"
 	"github.com/bitxeno/atvloadly/internal/ipa"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/manager"
@@ -21,6 +22,9 @@ func HandleInstallMessage(c *websocket.Conn) {
 	installMgr.OnOutput(func(line string) {
 		websocketMgr.WriteMessage(line)
 	})
+	installMgr.OnProgress(func(progress model.InstallProgress) {
+		websocketMgr.WriteMessage(progressMessage(progress))
+	})
 	defer installMgr.Close()
 
 	for {
@@ -49,6 +53,11 @@ func HandleInstallMessage(c *websocket.Conn) {
 				continue
 			}
 
+			if err := manager.AppOverrides(v).Validate(); err != nil {
+				_ = c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("ERROR: %s", err.Error())))
+				continue
+			}
+
 			dev, found := manager.GetDeviceByUDID(v.UDID)
 			if !found || dev == nil {
 				_ = c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("ERROR: device not found for UDID: %s", v.UDID)))
@@ -66,6 +75,14 @@ func HandleInstallMessage(c *websocket.Conn) {
 	}
 }
 
+// progressMessage encodes an install phase change as a message frame, told
+// apart from the raw output lines by its JSON form.
+func progressMessage(progress model.InstallProgress) string {
+	data, _ := json.Marshal(progress)
+	msg, _ := json.Marshal(model.Message{Type: model.MessageTypeProgress, Data: string(data)})
+	return string(msg)
+}
+
 func runInstallMessage(mgr *manager.WebsocketManager, installMgr *manager.InstallManager, v model.InstalledApp, dev *model.Device) {
 	ipaPath := v.IpaPath
 	if strings.HasPrefix(ipaPath, "http:") || strings.HasPrefix(ipaPath, "https:") {
@@ -101,7 +118,16 @@ func runInstallMessage(mgr *manager.WebsocketManager, installMgr *manager.Instal
 		v.Version = result.Version
 		v.Icon = result.IconPath
 	}
+	v.ApplyOverrides()
 
+	if err := CheckQuota(v, time.Now()); err != nil {
+		mgr.WriteMessage(fmt.Sprintf("ERROR: %s", err.Error()))
+		mgr.WriteMessage("\n")
+		mgr.WriteMessage("Installation Failed!")
+		return
+	}
+
+	startedAt := time.Now()
 	err := installMgr.Start(mgr.Context(), manager.InstallOptions{
 		UDID:             v.UDID,
 		Account:          v.Account,
@@ -110,8 +136,13 @@ func runInstallMessage(mgr *manager.WebsocketManager, installMgr *manager.Instal
 		IpaPath:          ipaPath,
 		RemoveExtensions: v.RemoveExtensions,
 		RefreshMode:      false,
+		Overrides:        manager.AppOverrides(v),
 	})
+	if err == nil && !installMgr.IsSuccess() {
+		err = fmt.Errorf("install failed with unknown error. %s", installMgr.ErrorLog())
+	}
 	if err != nil {
+		saveInstallAttempt(installMgr, installedAppID(v), startedAt, err)
 		installMgr.CleanTempFiles(v.IpaPath)
 		msg := fmt.Sprintf("ERROR: %s", err.Error())
 		mgr.WriteMessage(msg)
@@ -120,33 +151,77 @@ func runInstallMessage(mgr *manager.WebsocketManager, installMgr *manager.Instal
 		return
 	}
 
-	if installMgr.IsSuccess() {
-		now := time.Now()
-		expirationDate := now.AddDate(0, 0, 7)
-		if installMgr.ProvisioningProfile != nil {
-			expirationDate = installMgr.ProvisioningProfile.ExpirationDate.Local()
-		}
-		v.RefreshedDate = &now
-		v.ExpirationDate = &expirationDate
-		v.RefreshedResult = true
+	now := time.Now()
+	expirationDate := now.AddDate(0, 0, 7)
+	if installMgr.ProvisioningProfile != nil {
+		expirationDate = installMgr.ProvisioningProfile.ExpirationDate.Local()
+	}
+	v.RefreshedDate = &now
+	v.ExpirationDate = &expirationDate
+	v.RefreshedResult = true
 
-		app, err := SaveApp(v)
-		if err != nil {
-			installMgr.CleanTempFiles(v.IpaPath)
-			msg := fmt.Sprintf("ERROR: save app to db failed. %s", err.Error())
-			mgr.WriteMessage(msg)
-			mgr.WriteMessage("\n")
-			mgr.WriteMessage("Installation Failed!")
-			return
-		} else {
-			installMgr.SaveLog(app.ID)
-			mgr.WriteMessage("Installation Succeeded!")
+	app, err := SaveApp(v)
+	if err != nil {
+		saveInstallAttempt(installMgr, installedAppID(v), startedAt, err)
+		installMgr.CleanTempFiles(v.IpaPath)
+		msg := fmt.Sprintf("ERROR: save app to db failed. %s", err.Error())
+		mgr.WriteMessage(msg)
+		mgr.WriteMessage("\n")
+		mgr.WriteMessage("Installation Failed!")
+		return
+	} else {
+		installMgr.SaveLog(app.ID)
+		saveInstallAttempt(installMgr, app.ID, startedAt, nil)
+		if err := RecordAppIDRegistration(app.Account, app.BundleIdentifier, now); err != nil {
+			log.Err(err).Msgf("Failed to record App ID registration: %s", app.IpaName)
 		}
+		mgr.WriteMessage("Installation Succeeded!")
 	}
 
 	installMgr.CleanTempFiles(v.IpaPath)
 }
 
+// saveInstallAttempt adds an interactive install to the refresh history of the
+// app, failed when err is set. Failed installs of an app that was never
+// installed have no history to go to and are only logged.
+func saveInstallAttempt(installMgr *manager.InstallManager, appID uint, startedAt time.Time, err error) {
+	if appID == 0 {
+		return
+	}
+	now := time.Now()
+	attempt := model.RefreshAttempt{
+		AppID:      appID,
+		Attempt:    1,
+		Trigger:    model.RefreshTriggerManual,
+		StartedAt:  startedAt,
+		FinishedAt: &now,
+		Outcome:    model.AttemptOutcomeSucceeded,
+	}
+	if err != nil {
+		attempt.Outcome = model.AttemptOutcomeFailed
+		attempt.ErrorClass = string(installMgr.ClassifyFailure(err))
+		attempt.Error = err.Error()
+	}
+	if installMgr.ProvisioningProfile != nil {
+		attempt.ProfileUUID = installMgr.ProvisioningProfile.UUID
+	}
+	if err := SaveAttempt(&attempt); err != nil {
+		log.Err(err).Msgf("Failed to save refresh attempt of app %d", appID)
+		return
+	}
+	installMgr.SaveAttemptLog(attempt.ID)
+}
+
+// installedAppID returns the id of the earlier install of v, or 0 if there is
+// none.
+func installedAppID(v model.InstalledApp) uint {
+	var cur model.InstalledApp
+	if result := db.Store().Select("id").Where("udid=? and bundle_identifier=? and account=?", v.UDID, v.BundleIdentifier, v.Account).First(&cur); result.Error != nil {
+		return 0
+	}
+	return cur.ID
+}
+
 func HandleLoginMessage(c *websocket.Conn) {
 	websocketMgr := manager.NewWebsocketManager(c)
 	defer websocketMgr.Cancel()
@@ -0,0 +1,185 @@
+package task
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/notify"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+// BatchInfo is the progress of the jobs queued together by one
+// StartInstallApps call. Batches overlap when e.g. a manual refresh starts
+// while the scheduled one is running, each is notified once all its jobs are
+// done.
+type BatchInfo struct {
+	ID           string               `json:"id"`
+	Trigger      model.RefreshTrigger `json:"trigger"`
+	StartedAt    time.Time            `json:"started_at"`
+	TotalCount   int                  `json:"total_count"`
+	SuccessCount int                  `json:"success_count"`
+	FailedApps   []FailedAppInfo      `json:"failed_apps"`
+	Notify       bool                 `json:"notify"`
+}
+
+type FailedAppInfo struct {
+	AppName string            `json:"app_name"`
+	Account string            `json:"account"`
+	Error   string            `json:"error"`
+	Code    model.FailureCode `json:"code,omitempty"`
+}
+
+func (b *BatchInfo) CompletedCount() int {
+	return b.SuccessCount + len(b.FailedApps)
+}
+
+func (b *BatchInfo) IsComplete() bool {
+	return b.CompletedCount() >= b.TotalCount
+}
+
+func (t *Task) addBatch(batch *BatchInfo) {
+	t.batchMu.Lock()
+	defer t.batchMu.Unlock()
+	t.batches[batch.ID] = batch
+}
+
+// restoreBatch rebuilds the progress of a batch left unfinished by the
+// previous process.
+func (t *Task) restoreBatch(batchID string) {
+	jobs, err := service.GetBatchJobs(batchID)
+	if err != nil || len(jobs) == 0 {
+		return
+	}
+
+	batch := &BatchInfo{
+		ID:         batchID,
+		Trigger:    jobs[0].Trigger,
+		StartedAt:  jobs[0].CreatedAt,
+		TotalCount: len(jobs),
+		FailedApps: make([]FailedAppInfo, 0),
+		Notify:     jobs[0].Notify,
+	}
+	for _, job := range jobs {
+		switch job.State {
+		case model.JobStateSucceeded:
+			batch.SuccessCount++
+		case model.JobStateFailed, model.JobStateCancelled:
+			batch.FailedApps = append(batch.FailedApps, FailedAppInfo{
+				AppName: job.App.IpaName,
+				Account: job.App.Account,
+				Error:   job.Error,
+				Code:    model.FailureCode(job.ErrorClass),
+			})
+		}
+	}
+	t.addBatch(batch)
+}
+
+func (t *Task) trackBatchProgress(item TaskItem, success bool, err error) {
+	t.batchMu.Lock()
+	batch, ok := t.batches[item.BatchID]
+	if !ok {
+		t.batchMu.Unlock()
+		return
+	}
+
+	if success {
+		batch.SuccessCount++
+	} else {
+		batch.FailedApps = append(batch.FailedApps, FailedAppInfo{
+			AppName: item.App.IpaName,
+			Account: item.App.Account,
+			Error:   err.Error(),
+			Code:    classifyFailure(err),
+		})
+	}
+
+	complete := batch.IsComplete()
+	if complete {
+		delete(t.batches, batch.ID)
+	}
+	t.batchMu.Unlock()
+
+	// Batch complete, send aggregated notification
+	if complete {
+		t.sendBatchNotification(batch)
+	}
+}
+
+// activeBatches returns a copy of the unfinished batches, oldest first.
+func (t *Task) activeBatches() []BatchInfo {
+	t.batchMu.Lock()
+	defer t.batchMu.Unlock()
+
+	batches := make([]BatchInfo, 0, len(t.batches))
+	for _, batch := range t.batches {
+		v := *batch
+		v.FailedApps = append([]FailedAppInfo{}, batch.FailedApps...)
+		batches = append(batches, v)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].StartedAt.Before(batches[j].StartedAt)
+	})
+	return batches
+}
+
+func (t *Task) sendBatchNotification(batch *BatchInfo) {
+	if !batch.Notify || !app.Settings.Notification.Enabled {
+		return
+	}
+
+	switch app.Settings.Notification.Mode {
+	case app.NotifyModeDigest:
+		// reported by the daily digest
+		return
+	case app.NotifyModeBatch:
+		title := i18n.LocalizeF("notify.batch_summary_title", map[string]any{"success": batch.SuccessCount, "failed": len(batch.FailedApps)})
+		_ = notify.Send(title, batchSummary(batch))
+		return
+	}
+
+	if len(batch.FailedApps) > 0 {
+		// Some apps failed, send aggregated failure notification
+		var message strings.Builder
+		for _, failed := range batch.FailedApps {
+			message.WriteString(failureLine(failed))
+		}
+		title := i18n.LocalizeF("notify.batch_title", map[string]any{})
+		_ = notify.Send(title, message.String())
+	}
+}
+
+func batchSummary(batch *BatchInfo) string {
+	if len(batch.FailedApps) == 0 {
+		return i18n.LocalizeF("notify.batch_summary_content", map[string]any{"total": batch.TotalCount})
+	}
+
+	var message strings.Builder
+	for _, failed := range batch.FailedApps {
+		message.WriteString(failureLine(failed))
+	}
+	return message.String()
+}
+
+// failureLine formats a failed app of a batch with the remediation hint.
+func failureLine(failed FailedAppInfo) string {
+	line := i18n.LocalizeF("notify.batch_content", map[string]any{"name": failed.AppName, "error": failed.Error})
+	return line + failureHint(failed.Code) + "\n"
+}
+
+// failureHint returns the localized remediation hint line of code, or an
+// empty string when there is nothing to suggest.
+func failureHint(code model.FailureCode) string {
+	if code == "" || code == model.FailureUnknown {
+		return ""
+	}
+	return i18n.LocalizeF("notify.failure_hint", map[string]any{"hint": i18n.Localize(code.HintKey())})
+}
+
+func GetActiveBatches() []BatchInfo {
+	return instance.activeBatches()
+}
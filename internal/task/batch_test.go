@@ -0,0 +1,42 @@
+package task
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestTrackBatchProgressOverlappingBatches(t *testing.T) {
+	task := new()
+	now := time.Now()
+	task.addBatch(&BatchInfo{ID: "cron", StartedAt: now, TotalCount: 2})
+	task.addBatch(&BatchInfo{ID: "manual", StartedAt: now.Add(time.Minute), TotalCount: 1})
+
+	task.trackBatchProgress(TaskItem{BatchID: "cron", App: model.InstalledApp{IpaName: "a"}}, false, errors.New("device not found"))
+	task.trackBatchProgress(TaskItem{BatchID: "manual", App: model.InstalledApp{IpaName: "b"}}, true, nil)
+
+	batches := task.activeBatches()
+	if len(batches) != 1 || batches[0].ID != "cron" {
+		t.Fatalf("only the cron batch should be active, got %+v", batches)
+	}
+	if len(batches[0].FailedApps) != 1 || batches[0].FailedApps[0].AppName != "a" {
+		t.Fatalf("the failure should be kept on the cron batch, got %+v", batches[0].FailedApps)
+	}
+
+	task.trackBatchProgress(TaskItem{BatchID: "cron", App: model.InstalledApp{IpaName: "c"}}, true, nil)
+	if batches := task.activeBatches(); len(batches) != 0 {
+		t.Fatalf("all batches should be complete, got %+v", batches)
+	}
+}
+
+func TestTrackBatchProgressUnknownBatch(t *testing.T) {
+	task := new()
+	task.addBatch(&BatchInfo{ID: "cron", TotalCount: 1})
+
+	task.trackBatchProgress(TaskItem{BatchID: "other"}, true, nil)
+	if batches := task.activeBatches(); len(batches) != 1 || batches[0].CompletedCount() != 0 {
+		t.Fatalf("unknown batch should not change progress, got %+v", batches)
+	}
+}
@@ -0,0 +1,51 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+var (
+	ErrJobCancelled = errors.New("cancelled by user")
+	ErrJobNotActive = errors.New("job is not pending or running")
+)
+
+// CancelJob removes a pending job from the queue, or stops a running one.
+// A running job is finished by its worker once plumesign has been killed.
+func (t *Task) CancelJob(id uint) (*model.Job, error) {
+	job, err := service.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if !job.State.IsActive() {
+		return job, ErrJobNotActive
+	}
+
+	cancelled, err := service.CancelJob(id)
+	if err != nil {
+		return job, err
+	}
+	if cancelled {
+		log.Infof("Install job cancelled: %s", job.App.IpaName)
+		t.InstallingApps.Delete(id)
+		t.trackBatchProgress(TaskItem{JobID: job.ID, App: job.App, Notify: job.Notify, BatchID: job.BatchID}, false, ErrJobCancelled)
+		job.State = model.JobStateCancelled
+		return job, nil
+	}
+
+	// claimed by a worker in the meantime
+	if cancel, ok := t.runningJobs.Load(id); ok {
+		cancel.(context.CancelCauseFunc)(ErrJobCancelled)
+		return job, nil
+	}
+	return job, fmt.Errorf("job %d is starting, try again later", id)
+}
+
+func CancelJob(id uint) (*model.Job, error) {
+	return instance.CancelJob(id)
+}
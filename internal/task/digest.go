@@ -0,0 +1,223 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/notify"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	digestPeriod      = 24 * time.Hour
+	digestExpiringMax = 3
+	digestTimeLayout  = "2006-01-02 15:04"
+)
+
+// digest is the content of the daily notification.
+type digest struct {
+	Refreshed    []model.InstalledApp
+	Failed       []digestFailure
+	Expiring     []model.InstalledApp
+	StaleDevices []digestDevice
+}
+
+type digestFailure struct {
+	AppName string
+	Error   string
+	Code    model.FailureCode
+}
+
+type digestDevice struct {
+	Name     string
+	LastSeen *time.Time
+}
+
+func (d digest) isEmpty() bool {
+	return len(d.Refreshed) == 0 && len(d.Failed) == 0 && len(d.Expiring) == 0 && len(d.StaleDevices) == 0
+}
+
+// ValidateDigestTime checks a daily digest time in HH:MM format.
+func ValidateDigestTime(v string) error {
+	_, err := time.Parse("15:04", v)
+	return err
+}
+
+func (t *Task) addDigestSchedule() {
+	if app.Settings.Notification.Mode != app.NotifyModeDigest {
+		return
+	}
+
+	at, err := time.Parse("15:04", app.Settings.Notification.DigestTime)
+	if err != nil {
+		log.Err(err).Msgf("Invalid daily digest time: %s", app.Settings.Notification.DigestTime)
+		return
+	}
+	// the digest has its own cron, it is sent when scheduled refresh is disabled too
+	spec := fmt.Sprintf("%d %d * * *", at.Minute(), at.Hour())
+	t.digestCron = cron.New()
+	if _, err := t.digestCron.AddFunc(spec, t.sendDigest); err != nil {
+		log.Err(err).Msg("Failed to schedule the daily digest")
+		t.digestCron = nil
+	}
+}
+
+func (t *Task) sendDigest() {
+	if !app.Settings.Notification.Enabled {
+		return
+	}
+
+	now := time.Now()
+	apps, err := service.GetEnableAppList()
+	if err != nil {
+		log.Err(err).Msg("Failed to load apps for the daily digest")
+		return
+	}
+	attempts, err := service.GetAttemptsSince(now.Add(-digestPeriod))
+	if err != nil {
+		log.Err(err).Msg("Failed to load refresh history for the daily digest")
+		return
+	}
+	connected := make(map[string]bool)
+	devices, _ := manager.GetDevices()
+	for _, dev := range devices {
+		connected[dev.UDID] = true
+	}
+
+	d := collectDigest(now, apps, attempts, connected, app.Settings.Notification.StaleDeviceDays)
+	if d.isEmpty() {
+		return
+	}
+	title := i18n.LocalizeF("notify.digest_title", map[string]any{})
+	if err := notify.Send(title, d.message()); err != nil {
+		log.Err(err).Msg("Failed to send the daily digest")
+	}
+}
+
+// collectDigest picks the apps refreshed and failed within the last day, the
+// apps expiring soonest and the devices of installed apps that are not
+// connected and have had no successful refresh for staleDays.
+func collectDigest(now time.Time, apps []model.InstalledApp, attempts []model.RefreshAttempt, connected map[string]bool, staleDays int) digest {
+	var d digest
+	since := now.Add(-digestPeriod)
+
+	names := make(map[uint]string, len(apps))
+	for _, v := range apps {
+		names[v.ID] = v.IpaName
+		if v.RefreshedResult && v.RefreshedDate != nil && v.RefreshedDate.After(since) {
+			d.Refreshed = append(d.Refreshed, v)
+		}
+	}
+
+	// only the last failure of each app, apps refreshed after it are left out
+	last := make(map[uint]model.RefreshAttempt)
+	appOrder := make([]uint, 0)
+	for _, attempt := range attempts {
+		if _, ok := names[attempt.AppID]; !ok {
+			continue
+		}
+		if attempt.Outcome != model.AttemptOutcomeFailed && attempt.Outcome != model.AttemptOutcomeSucceeded {
+			continue
+		}
+		if _, ok := last[attempt.AppID]; !ok {
+			appOrder = append(appOrder, attempt.AppID)
+		}
+		last[attempt.AppID] = attempt
+	}
+	for _, id := range appOrder {
+		if attempt := last[id]; attempt.Outcome == model.AttemptOutcomeFailed {
+			d.Failed = append(d.Failed, digestFailure{AppName: names[id], Error: attempt.Error, Code: model.FailureCode(attempt.ErrorClass)})
+		}
+	}
+
+	d.Expiring = append([]model.InstalledApp{}, apps...)
+	sort.SliceStable(d.Expiring, func(i, j int) bool {
+		a, b := d.Expiring[i].ExpirationDate, d.Expiring[j].ExpirationDate
+		// apps without an expiration date are not about to expire
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Before(*b)
+	})
+	if len(d.Expiring) > digestExpiringMax {
+		d.Expiring = d.Expiring[:digestExpiringMax]
+	}
+
+	lastSeen := make(map[string]*time.Time)
+	order := make([]string, 0)
+	deviceNames := make(map[string]string)
+	for _, v := range apps {
+		if v.UDID == "" || connected[v.UDID] {
+			continue
+		}
+		if _, ok := deviceNames[v.UDID]; !ok {
+			order = append(order, v.UDID)
+			deviceNames[v.UDID] = v.Device
+			lastSeen[v.UDID] = nil
+		}
+		if v.RefreshedResult && v.RefreshedDate != nil && (lastSeen[v.UDID] == nil || v.RefreshedDate.After(*lastSeen[v.UDID])) {
+			lastSeen[v.UDID] = v.RefreshedDate
+		}
+	}
+	staleBefore := now.AddDate(0, 0, -staleDays)
+	for _, udid := range order {
+		if seen := lastSeen[udid]; seen == nil || seen.Before(staleBefore) {
+			d.StaleDevices = append(d.StaleDevices, digestDevice{Name: deviceNames[udid], LastSeen: seen})
+		}
+	}
+
+	return d
+}
+
+func (d digest) message() string {
+	sections := make([]string, 0, 4)
+	if len(d.Refreshed) > 0 {
+		var b strings.Builder
+		b.WriteString(i18n.LocalizeF("notify.digest_refreshed", map[string]any{}))
+		for _, v := range d.Refreshed {
+			b.WriteString(i18n.LocalizeF("notify.digest_app_line", map[string]any{"name": v.IpaName, "expiration": formatDigestTime(v.ExpirationDate)}))
+		}
+		sections = append(sections, b.String())
+	}
+	if len(d.Failed) > 0 {
+		var b strings.Builder
+		b.WriteString(i18n.LocalizeF("notify.digest_failed", map[string]any{}))
+		for _, v := range d.Failed {
+			b.WriteString(i18n.LocalizeF("notify.digest_failed_line", map[string]any{"name": v.AppName, "error": v.Error}))
+			b.WriteString(failureHint(v.Code))
+		}
+		sections = append(sections, b.String())
+	}
+	if len(d.Expiring) > 0 {
+		var b strings.Builder
+		b.WriteString(i18n.LocalizeF("notify.digest_expiring", map[string]any{}))
+		for _, v := range d.Expiring {
+			b.WriteString(i18n.LocalizeF("notify.digest_expiring_line", map[string]any{"name": v.IpaName, "device": v.Device, "expiration": formatDigestTime(v.ExpirationDate)}))
+		}
+		sections = append(sections, b.String())
+	}
+	if len(d.StaleDevices) > 0 {
+		var b strings.Builder
+		b.WriteString(i18n.LocalizeF("notify.digest_stale_devices", map[string]any{}))
+		for _, v := range d.StaleDevices {
+			b.WriteString(i18n.LocalizeF("notify.digest_device_line", map[string]any{"name": v.Name, "last_seen": formatDigestTime(v.LastSeen)}))
+		}
+		sections = append(sections, b.String())
+	}
+	return strings.Join(sections, "\n")
+}
+
+func formatDigestTime(v *time.Time) string {
+	if v == nil {
+		return "-"
+	}
+	return v.Local().Format(digestTimeLayout)
+}
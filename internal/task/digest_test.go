@@ -0,0 +1,94 @@
+package task
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestCollectDigest(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	apps := []model.InstalledApp{
+		{IpaName: "refreshed", UDID: "tv", Device: "Living Room", RefreshedResult: true, RefreshedDate: at(-2 * time.Hour), ExpirationDate: at(7 * 24 * time.Hour)},
+		{IpaName: "soon", UDID: "tv", Device: "Living Room", RefreshedResult: true, RefreshedDate: at(-6 * 24 * time.Hour), ExpirationDate: at(12 * time.Hour)},
+		{IpaName: "stale", UDID: "phone", Device: "iPhone", RefreshedResult: true, RefreshedDate: at(-5 * 24 * time.Hour), ExpirationDate: at(2 * 24 * time.Hour)},
+		// no expiration date, it sorts after the apps about to expire
+		{IpaName: "never", UDID: "ipad", Device: "iPad"},
+	}
+	for i := range apps {
+		apps[i].ID = uint(i + 1)
+	}
+	attempts := []model.RefreshAttempt{
+		{AppID: 2, Outcome: model.AttemptOutcomeFailed, Error: "first"},
+		{AppID: 2, Outcome: model.AttemptOutcomeFailed, Error: "second"},
+		{AppID: 1, Outcome: model.AttemptOutcomeSucceeded},
+		{AppID: 3, Outcome: model.AttemptOutcomeFailed, Error: "retried"},
+		{AppID: 3, Outcome: model.AttemptOutcomeSucceeded},
+		{AppID: 99, Outcome: model.AttemptOutcomeFailed, Error: "deleted app"},
+	}
+
+	d := collectDigest(now, apps, attempts, map[string]bool{"tv": true}, 3)
+
+	if len(d.Refreshed) != 1 || d.Refreshed[0].IpaName != "refreshed" {
+		t.Fatalf("unexpected refreshed apps: %+v", d.Refreshed)
+	}
+	if len(d.Failed) != 1 || d.Failed[0].AppName != "soon" || d.Failed[0].Error != "second" {
+		t.Fatalf("expected the last failure of each app not refreshed since, got %+v", d.Failed)
+	}
+	var expiring []string
+	for _, v := range d.Expiring {
+		expiring = append(expiring, v.IpaName)
+	}
+	if got := strings.Join(expiring, ","); got != "soon,stale,refreshed" {
+		t.Fatalf("unexpected expiring order: %s", got)
+	}
+	if len(d.StaleDevices) != 2 || d.StaleDevices[0].Name != "iPhone" || d.StaleDevices[1].Name != "iPad" || d.StaleDevices[1].LastSeen != nil {
+		t.Fatalf("unexpected stale devices: %+v", d.StaleDevices)
+	}
+}
+
+func TestDigestScheduleWithRefreshDisabled(t *testing.T) {
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Task.Enabled = false
+	app.Settings.Notification.Mode = app.NotifyModeDigest
+	app.Settings.Notification.DigestTime = "08:30"
+
+	task := new()
+	task.addDigestSchedule()
+	if task.digestCron == nil || len(task.digestCron.Entries()) != 1 {
+		t.Fatal("the digest should be scheduled on its own cron")
+	}
+
+	app.Settings.Notification.Mode = ""
+	task = new()
+	task.addDigestSchedule()
+	if task.digestCron != nil {
+		t.Fatal("the digest should not be scheduled when off")
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	i18n.Init(os.DirFS("../.."))
+
+	expiration := time.Date(2026, 10, 25, 4, 0, 0, 0, time.Local)
+	d := digest{
+		Refreshed:    []model.InstalledApp{{IpaName: "Kodi", ExpirationDate: &expiration}},
+		StaleDevices: []digestDevice{{Name: "iPhone"}},
+	}
+	msg := d.message()
+	for _, want := range []string{"Kodi: expires 2026-10-25 04:00", "iPhone: last refreshed -"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("digest message should contain %q, got:\n%s", want, msg)
+		}
+	}
+}
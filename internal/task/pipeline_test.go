@@ -0,0 +1,335 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/manager/fake"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+const (
+	pipelineAccount = "fake@example.com"
+	pipelineUDID    = "00008110-FAKE"
+)
+
+// setupPipeline runs the task pipeline against a temp database and the fake
+// backend, with one connected device.
+func setupPipeline(t *testing.T) (*Task, *fake.Backend, model.InstalledApp) {
+	dir := t.TempDir()
+	app.Config = &app.Configuration{}
+	app.Config.Server.DataDir = dir
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Task.MaxConcurrency = 1
+
+	if err := db.Open(db.Config{Path: dir, FileName: "test.db"}).AutoMigrate(
+		&model.InstalledApp{},
+		&model.Job{},
+		&model.RefreshAttempt{},
+		&model.AppIDRegistration{},
+		&model.IpaVersion{},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := fake.New()
+	manager.SetBackend(backend, backend)
+	device := model.Device{ID: "fake-device", UDID: pipelineUDID, Name: "Apple TV", Status: model.Paired, Connection: model.DeviceConnectionLockdown}
+	manager.SaveDevice(device)
+	t.Cleanup(func() {
+		manager.DeleteDevice(device.ID)
+		manager.SetBackend(nil, nil)
+		_ = db.Close()
+	})
+
+	expiration := time.Now().AddDate(0, 0, 1)
+	v := model.InstalledApp{
+		IpaName:          "Fake",
+		IpaPath:          filepath.Join(dir, "fakeapp.ipa"),
+		BundleIdentifier: "com.example.fake",
+		Account:          pipelineAccount,
+		UDID:             pipelineUDID,
+		Enabled:          true,
+		ExpirationDate:   &expiration,
+	}
+	if err := db.Store().Create(&v).Error; err != nil {
+		t.Fatal(err)
+	}
+	return new(), backend, v
+}
+
+// runPipeline queues a manual refresh of v and waits for its job to finish.
+func runPipeline(t *testing.T, tk *Task, v model.InstalledApp, during func(job model.Job)) model.Job {
+	tk.StartInstallApps([]model.InstalledApp{v}, false, model.RefreshTriggerManual)
+	tk.dispatch()
+	jobs, err := service.GetJobList("", 1)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("GetJobList = %v, %v, want one job", jobs, err)
+	}
+	if during != nil {
+		during(jobs[0])
+	}
+	tk.jobsWG.Wait()
+
+	job, err := service.GetJob(jobs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *job
+}
+
+func TestPipelineInstallSuccess(t *testing.T) {
+	tk, backend, v := setupPipeline(t)
+
+	job := runPipeline(t, tk, v, nil)
+	if job.State != model.JobStateSucceeded {
+		t.Fatalf("job state = %s (%s), want succeeded", job.State, job.Error)
+	}
+	if job.Progress == nil || job.Progress.Phase != model.InstallPhaseDone {
+		t.Fatalf("job progress = %+v, want done", job.Progress)
+	}
+	installs := backend.Installs()
+	if len(installs) != 1 || installs[0].UDID != pipelineUDID || !installs[0].RefreshMode {
+		t.Fatalf("installs = %+v, want one refresh on %s", installs, pipelineUDID)
+	}
+
+	cur, err := service.GetApp(v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cur.RefreshedResult || cur.ExpirationDate == nil || time.Until(*cur.ExpirationDate) < 6*24*time.Hour {
+		t.Fatalf("app = %+v, want refreshed for 7 days", cur)
+	}
+	attempts, err := service.GetJobAttempts(job.ID)
+	if err != nil || len(attempts) != 1 || attempts[0].Outcome != model.AttemptOutcomeSucceeded {
+		t.Fatalf("attempts = %+v, %v, want one succeeded attempt", attempts, err)
+	}
+}
+
+func TestPipelineInstallFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		script fake.Script
+		code   model.FailureCode
+	}{
+		{"account error", fake.AccountError(), model.FailureAccountInvalid},
+		{"unattended 2fa", fake.TwoFactor("123456", 50*time.Millisecond), model.FailureTwoFactorRequired},
+		{"disk full", fake.Script{InstallError: "AMDNoSpaceError"}, model.FailureDeviceDiskFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk, backend, v := setupPipeline(t)
+			backend.Script(pipelineAccount, tt.script)
+
+			job := runPipeline(t, tk, v, nil)
+			if job.State != model.JobStateFailed || job.ErrorClass != string(tt.code) {
+				t.Fatalf("job = %s %s (%s), want failed %s without retry", job.State, job.ErrorClass, job.Error, tt.code)
+			}
+			cur, err := service.GetApp(v.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cur.RefreshedResult || cur.FailureCode != tt.code {
+				t.Fatalf("app failure code = %s, want %s", cur.FailureCode, tt.code)
+			}
+		})
+	}
+}
+
+func TestPipelineCancelSlowInstall(t *testing.T) {
+	tk, backend, v := setupPipeline(t)
+	backend.Script(pipelineAccount, fake.Slow(time.Second))
+
+	job := runPipeline(t, tk, v, func(job model.Job) {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := tk.CancelJob(job.ID); err != nil {
+			t.Errorf("CancelJob = %v", err)
+		}
+	})
+	if job.State != model.JobStateCancelled {
+		t.Fatalf("job state = %s (%s), want cancelled", job.State, job.Error)
+	}
+	attempts, err := service.GetJobAttempts(job.ID)
+	if err != nil || len(attempts) != 1 || attempts[0].Outcome != model.AttemptOutcomeCancelled {
+		t.Fatalf("attempts = %+v, %v, want one cancelled attempt", attempts, err)
+	}
+}
+
+func TestPipelineCancelCleansTempFilesAfterOtherInstall(t *testing.T) {
+	tk, backend, v := setupPipeline(t)
+	app.Settings.Task.MaxConcurrency = 2
+	backend.Script(pipelineAccount, fake.Slow(time.Second))
+
+	const otherAccount = "other@example.com"
+	other := model.Device{ID: "fake-device-2", UDID: "00008110-FAKE2", Name: "Apple TV 2", Status: model.Paired, Connection: model.DeviceConnectionLockdown}
+	manager.SaveDevice(other)
+	t.Cleanup(func() { manager.DeleteDevice(other.ID) })
+	backend.Script(otherAccount, fake.Slow(50*time.Millisecond))
+	v2 := v
+	v2.ID = 0
+	v2.IpaName = "Other"
+	v2.IpaPath = filepath.Join(app.Config.Server.DataDir, "otherapp.ipa")
+	v2.BundleIdentifier = "com.example.other"
+	v2.Account = otherAccount
+	v2.UDID = other.UDID
+	if err := db.Store().Create(&v2).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// temp files left by the install of fakeapp.ipa
+	tmpDir := filepath.Join(app.Config.Server.DataDir, "tmp")
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile := filepath.Join(tmpDir, "fakeapp_extracted.ipa")
+	if err := os.WriteFile(tmpFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tk.StartInstallApps([]model.InstalledApp{v, v2}, false, model.RefreshTriggerManual)
+	tk.dispatch()
+	jobs, err := service.GetJobList("", 2)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("GetJobList = %v, %v, want two jobs", jobs, err)
+	}
+	var slow, fast model.Job
+	for _, job := range jobs {
+		if job.AppID == v.ID {
+			slow = job
+		} else {
+			fast = job
+		}
+	}
+	waitJobState(t, fast.ID, model.JobStateRunning)
+	if _, err := tk.CancelJob(slow.ID); err != nil {
+		t.Fatalf("CancelJob = %v", err)
+	}
+	waitJobState(t, slow.ID, model.JobStateCancelled)
+	tk.jobsWG.Wait()
+
+	if job, _ := service.GetJob(fast.ID); job.State != model.JobStateSucceeded {
+		t.Fatalf("other job state = %s (%s), want succeeded", job.State, job.Error)
+	}
+	if _, err := os.Stat(tmpFile); !os.IsNotExist(err) {
+		t.Fatalf("temp file of the cancelled job: %v, want removed once the other install finished", err)
+	}
+}
+
+func TestRunFallsBackForInvalidAppSchedule(t *testing.T) {
+	tk, _, v := setupPipeline(t)
+	app.Settings.Task.AdvanceDays = 3
+
+	// an app with its own schedule is left to it
+	if err := db.Store().Model(&v).Update("refresh_cron", "0 4 * * 6,0").Error; err != nil {
+		t.Fatal(err)
+	}
+	tk.Run()
+	if jobs, _ := service.GetJobList("", 10); len(jobs) != 0 {
+		t.Fatalf("jobs = %+v, want none for an app with its own schedule", jobs)
+	}
+
+	if err := db.Store().Model(&v).Update("refresh_cron", "every sunday").Error; err != nil {
+		t.Fatal(err)
+	}
+	tk.Run()
+	jobs, err := service.GetJobList("", 10)
+	if err != nil || len(jobs) != 1 || jobs[0].AppID != v.ID {
+		t.Fatalf("jobs = %+v, %v, want the app refreshed by the global schedule", jobs, err)
+	}
+}
+
+func TestPipelineRollbackReinstalls(t *testing.T) {
+	tk, backend, v := setupPipeline(t)
+
+	kept := filepath.Join(app.Config.Server.DataDir, "kept.ipa")
+	if err := os.WriteFile(kept, []byte("1.0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(app.Config.Server.DataDir, "ipa", fmt.Sprintf("%d", v.ID)), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	version := model.IpaVersion{AppID: v.ID, Version: "1.0", Build: "100", Path: kept, InstalledAt: time.Now().AddDate(0, 0, -7)}
+	if err := db.Store().Create(&version).Error; err != nil {
+		t.Fatal(err)
+	}
+	restored, err := service.RestoreIpaVersion(v.ID, version.ID)
+	if err != nil {
+		t.Fatalf("RestoreIpaVersion = %v", err)
+	}
+
+	tk.ReinstallApp(*restored, model.RefreshTriggerManual)
+	tk.dispatch()
+	tk.jobsWG.Wait()
+
+	installs := backend.Installs()
+	if len(installs) != 1 || installs[0].IpaPath != restored.IpaPath || installs[0].RefreshMode {
+		t.Fatalf("installs = %+v, want one install of %s without refresh mode", installs, restored.IpaPath)
+	}
+	if data, _ := os.ReadFile(installs[0].IpaPath); string(data) != "1.0" {
+		t.Fatalf("installed ipa = %q, want the restored one", data)
+	}
+}
+
+// waitJobState polls the job until it reaches state.
+func waitJobState(t *testing.T, id uint, state model.JobState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, err := service.GetJob(id); err == nil && job.State == state {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not reach state %s", id, state)
+}
+
+func TestPipelineTwoFactorCode(t *testing.T) {
+	tk, backend, v := setupPipeline(t)
+	backend.Script(pipelineAccount, fake.TwoFactor("123456", 0))
+
+	job := runPipeline(t, tk, v, func(job model.Job) {
+		waitJobState(t, job.ID, model.JobStateAwaiting2FA)
+		if cur, err := service.GetJob(job.ID); err != nil || cur.CodeExpiresAt == nil {
+			t.Errorf("job code expiry = %v, %v, want set", cur, err)
+		}
+		if err := tk.SubmitCode(job.ID, "12345"); err != ErrInvalidCode {
+			t.Errorf("SubmitCode(12345) = %v, want %v", err, ErrInvalidCode)
+		}
+		if err := tk.SubmitCode(job.ID, " 123456 "); err != nil {
+			t.Errorf("SubmitCode = %v", err)
+		}
+		if err := tk.SubmitCode(job.ID, "123456"); err != ErrNoCodePrompt {
+			t.Errorf("second SubmitCode = %v, want %v", err, ErrNoCodePrompt)
+		}
+	})
+	if job.State != model.JobStateSucceeded || job.CodeExpiresAt != nil {
+		t.Fatalf("job = %s (%s), want succeeded", job.State, job.Error)
+	}
+}
+
+func TestPipelineTwoFactorCodeExpired(t *testing.T) {
+	defer func(timeout time.Duration) { codeTimeout = timeout }(codeTimeout)
+	codeTimeout = 50 * time.Millisecond
+
+	tk, backend, v := setupPipeline(t)
+	backend.Script(pipelineAccount, fake.TwoFactor("123456", 0))
+
+	job := runPipeline(t, tk, v, nil)
+	if job.State != model.JobStateFailed || job.ErrorClass != string(model.FailureTwoFactorRequired) {
+		t.Fatalf("job = %s %s (%s), want failed %s", job.State, job.ErrorClass, job.Error, model.FailureTwoFactorRequired)
+	}
+	cur, err := service.GetApp(v.ID)
+	if err != nil || cur.FailureCode != model.FailureTwoFactorRequired {
+		t.Fatalf("app = %+v, %v, want failure code %s", cur, err, model.FailureTwoFactorRequired)
+	}
+	if err := tk.SubmitCode(job.ID, "123456"); err != ErrNoCodePrompt {
+		t.Fatalf("SubmitCode after expiry = %v, want %v", err, ErrNoCodePrompt)
+	}
+}
@@ -0,0 +1,146 @@
+package task
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/robfig/cron/v3"
+)
+
+// SkipReason tells why a scheduled run does not refresh an app.
+type SkipReason string
+
+const (
+	SkipNotDue         SkipReason = "not_due"
+	SkipAccountInvalid SkipReason = "account_invalid"
+	SkipDeviceOffline  SkipReason = "device_offline"
+	SkipAfcUnavailable SkipReason = "afc_unavailable"
+	SkipOwnSchedule    SkipReason = "own_schedule"
+)
+
+const (
+	defaultPlanFirings = 5
+	maxPlanFirings     = 50
+)
+
+// PlanOptions overrides the settings a refresh plan is computed with, so a
+// change can be previewed before it is saved. Zero values use the settings.
+type PlanOptions struct {
+	Cron        string
+	AdvanceDays int
+	Firings     int
+}
+
+// RefreshPlan is what the scheduled refresh would do at its next firings.
+type RefreshPlan struct {
+	Enabled     bool         `json:"enabled"`
+	Cron        string       `json:"cron"`
+	AdvanceDays int          `json:"advance_days"`
+	FireTimes   []time.Time  `json:"fire_times"`
+	Firings     []PlanFiring `json:"firings"`
+}
+
+type PlanFiring struct {
+	At time.Time `json:"at"`
+	// DeviceChecked is set for the first firing only, later ones assume the
+	// devices are reachable as their state is unknown.
+	DeviceChecked bool      `json:"device_checked"`
+	Refresh       []PlanApp `json:"refresh"`
+	Skipped       []PlanApp `json:"skipped"`
+}
+
+type PlanApp struct {
+	ID     uint       `json:"id"`
+	Name   string     `json:"name"`
+	Device string     `json:"device"`
+	DueAt  *time.Time `json:"due_at"`
+	Reason SkipReason `json:"reason,omitempty"`
+}
+
+// PreviewRefreshPlan runs the selection of Task.Run for the next firings of
+// the schedule without installing anything. Device checks reflect the current
+// state and only apply to the first firing. An app planned for refresh is
+// assumed to succeed, so it is not due at the later firings.
+func PreviewRefreshPlan(opts PlanOptions) (*RefreshPlan, error) {
+	apps, err := service.GetEnableAppList()
+	if err != nil {
+		return nil, err
+	}
+
+	// device checks run plumesign, only once per device
+	checked := make(map[string]SkipReason)
+	device := func(v model.InstalledApp) SkipReason {
+		if reason, ok := checked[v.UDID]; ok {
+			return reason
+		}
+		reason := checkDevice(v)
+		checked[v.UDID] = reason
+		return reason
+	}
+	return buildRefreshPlan(apps, opts, time.Now(), device)
+}
+
+func buildRefreshPlan(apps []model.InstalledApp, opts PlanOptions, now time.Time, device func(model.InstalledApp) SkipReason) (*RefreshPlan, error) {
+	plan := &RefreshPlan{
+		Enabled:     app.Settings.Task.Enabled,
+		Cron:        opts.Cron,
+		AdvanceDays: opts.AdvanceDays,
+	}
+	if plan.Cron == "" {
+		plan.Cron = app.Settings.Task.CrodTime
+	}
+	if plan.AdvanceDays <= 0 {
+		plan.AdvanceDays = app.Settings.Task.AdvanceDays
+	}
+	count := opts.Firings
+	if count <= 0 {
+		count = defaultPlanFirings
+	}
+	count = min(count, maxPlanFirings)
+
+	schedule, err := cron.ParseStandard(plan.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time format: %w", err)
+	}
+
+	apps = append([]model.InstalledApp{}, apps...)
+	plan.FireTimes = make([]time.Time, 0, count)
+	plan.Firings = make([]PlanFiring, 0, count)
+	at := now
+	for range count {
+		at = schedule.Next(at)
+		if at.IsZero() {
+			break
+		}
+		plan.FireTimes = append(plan.FireTimes, at)
+
+		firing := PlanFiring{At: at, DeviceChecked: len(plan.Firings) == 0, Refresh: make([]PlanApp, 0), Skipped: make([]PlanApp, 0)}
+		check := device
+		if !firing.DeviceChecked {
+			check = func(model.InstalledApp) SkipReason { return "" }
+		}
+		for i, v := range apps {
+			item := PlanApp{ID: v.ID, Name: v.IpaName, Device: v.Device, DueAt: v.RefreshDueTime(plan.AdvanceDays)}
+			if v.RefreshCron != "" && ValidateSchedule(v.RefreshCron) == nil {
+				item.Reason = SkipOwnSchedule
+			} else {
+				item.Reason = skipReason(v, at, plan.AdvanceDays, check)
+			}
+			if item.Reason != "" {
+				firing.Skipped = append(firing.Skipped, item)
+				continue
+			}
+			firing.Refresh = append(firing.Refresh, item)
+
+			refreshedAt := at
+			expiration := at.AddDate(0, 0, 7)
+			apps[i].RefreshedDate = &refreshedAt
+			apps[i].ExpirationDate = &expiration
+		}
+		plan.Firings = append(plan.Firings, firing)
+	}
+	return plan, nil
+}
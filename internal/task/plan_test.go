@@ -0,0 +1,81 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestBuildRefreshPlan(t *testing.T) {
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Task.Enabled = true
+	app.Settings.Task.CrodTime = "0 3 * * *"
+	app.Settings.Task.AdvanceDays = 1
+
+	now := time.Date(2026, 10, 5, 12, 0, 0, 0, time.Local)
+	refreshed := now.AddDate(0, 0, -6)
+	expiresSoon := time.Date(2026, 10, 6, 12, 0, 0, 0, time.Local)
+	expiresLater := time.Date(2026, 10, 8, 12, 0, 0, 0, time.Local)
+
+	apps := []model.InstalledApp{
+		{IpaName: "due", UDID: "tv", RefreshedDate: &refreshed, ExpirationDate: &expiresSoon},
+		{IpaName: "later", UDID: "tv", RefreshedDate: &refreshed, ExpirationDate: &expiresLater},
+		{IpaName: "invalid", UDID: "tv", RefreshedDate: &refreshed, ExpirationDate: &expiresSoon, RefreshedError: model.RefreshedErrorInvalidAccount},
+		{IpaName: "offline", UDID: "phone", RefreshedDate: &refreshed, ExpirationDate: &expiresSoon},
+		{IpaName: "own", UDID: "tv", RefreshCron: "0 4 * * *", RefreshedDate: &refreshed, ExpirationDate: &expiresSoon},
+		{IpaName: "bad cron", UDID: "tv", RefreshCron: "every sunday", RefreshedDate: &refreshed, ExpirationDate: &expiresSoon},
+	}
+	device := func(v model.InstalledApp) SkipReason {
+		if v.UDID == "phone" {
+			return SkipDeviceOffline
+		}
+		return ""
+	}
+
+	plan, err := buildRefreshPlan(apps, PlanOptions{Firings: 3}, now, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.FireTimes) != 3 || !plan.FireTimes[0].Equal(time.Date(2026, 10, 6, 3, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected fire times: %v", plan.FireTimes)
+	}
+
+	reasons := func(f PlanFiring) map[string]SkipReason {
+		m := make(map[string]SkipReason)
+		for _, v := range f.Refresh {
+			m[v.Name] = ""
+		}
+		for _, v := range f.Skipped {
+			m[v.Name] = v.Reason
+		}
+		return m
+	}
+	first := reasons(plan.Firings[0])
+	want := map[string]SkipReason{"due": "", "later": SkipNotDue, "invalid": SkipAccountInvalid, "offline": SkipDeviceOffline, "own": SkipOwnSchedule, "bad cron": ""}
+	for name, reason := range want {
+		if got, ok := first[name]; !ok || got != reason {
+			t.Errorf("first firing %s: got %q, want %q", name, got, reason)
+		}
+	}
+
+	// "due" is assumed refreshed, "later" becomes due on 10/7
+	if got := reasons(plan.Firings[1])["due"]; got != SkipNotDue {
+		t.Errorf("refreshed app should not be due again, got %q", got)
+	}
+	if got := reasons(plan.Firings[2])["later"]; got != "" {
+		t.Errorf("later should be refreshed at the third firing, got %q", got)
+	}
+	// the device state is only known for the first firing
+	if !plan.Firings[0].DeviceChecked || plan.Firings[1].DeviceChecked {
+		t.Errorf("device_checked = %v, %v, want first firing only", plan.Firings[0].DeviceChecked, plan.Firings[1].DeviceChecked)
+	}
+	if got := reasons(plan.Firings[1])["offline"]; got != "" {
+		t.Errorf("offline app at the second firing: got %q, want planned", got)
+	}
+
+	if _, err := buildRefreshPlan(apps, PlanOptions{Cron: "bad"}, now, device); err == nil {
+		t.Error("invalid cron expression should be rejected")
+	}
+}
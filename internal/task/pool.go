@@ -0,0 +1,171 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+// Delay before the next install on the same device.
+const deviceCooldown = 10 * time.Second
+
+// workerPool tracks the running jobs. Jobs for the same device run one after
+// another, different devices run in parallel up to Task.MaxConcurrency, and
+// installs with the same account start at least Task.AccountInterval apart.
+type workerPool struct {
+	mu            sync.Mutex
+	running       int
+	busyDevices   map[string]bool
+	accountStarts map[string]time.Time
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{
+		busyDevices:   make(map[string]bool),
+		accountStarts: make(map[string]time.Time),
+	}
+}
+
+// reserve takes a worker slot for job. When the job is throttled by its
+// account it returns how long to wait before trying again. The account is
+// throttled from started on, once the job is claimed.
+func (p *workerPool) reserve(job model.Job, now time.Time) (bool, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running >= maxConcurrency() || p.busyDevices[job.App.UDID] {
+		return false, 0
+	}
+	if last, ok := p.accountStarts[job.App.Account]; ok {
+		if wait := last.Add(accountInterval()).Sub(now); wait > 0 {
+			return false, wait
+		}
+	}
+
+	p.running++
+	p.busyDevices[job.App.UDID] = true
+	return true, 0
+}
+
+// started throttles the account of a reserved job that has been claimed.
+func (p *workerPool) started(job model.Job, now time.Time) {
+	p.mu.Lock()
+	p.accountStarts[job.App.Account] = now
+	p.mu.Unlock()
+}
+
+// release frees the worker slot, the device stays busy for cooldown.
+func (p *workerPool) release(job model.Job, cooldown time.Duration, done func()) {
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+
+	freeDevice := func() {
+		p.mu.Lock()
+		delete(p.busyDevices, job.App.UDID)
+		p.mu.Unlock()
+		done()
+	}
+	if cooldown <= 0 {
+		freeDevice()
+		return
+	}
+	time.AfterFunc(cooldown, freeDevice)
+}
+
+func (p *workerPool) full() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running >= maxConcurrency()
+}
+
+func maxConcurrency() int {
+	if app.Settings.Task.MaxConcurrency < 1 {
+		return 1
+	}
+	return app.Settings.Task.MaxConcurrency
+}
+
+func accountInterval() time.Duration {
+	return time.Duration(app.Settings.Task.AccountInterval) * time.Second
+}
+
+func (t *Task) runQueue() {
+	// Wait for one minute before install at startup to avoid the usbmuxd service not being ready.
+	manager.Usbmuxd().TryWaitReady(30 * time.Second)
+
+	for {
+		wait := time.Minute
+		if next := t.dispatch(); next > 0 && next < wait {
+			wait = next
+		}
+		if retryAt, err := service.NextRetryTime(); err == nil && retryAt != nil {
+			if next := time.Until(*retryAt); next < wait {
+				wait = next
+			}
+		}
+
+		select {
+		case <-t.chWakeQueue:
+		case <-time.After(wait):
+		case <-t.chExitQueue:
+			log.Info("Install app queue exit.")
+			return
+		}
+	}
+}
+
+// dispatch starts every pending job that has a free worker and returns the
+// delay until the next job held back by account throttling can start.
+func (t *Task) dispatch() time.Duration {
+	jobs, err := service.GetPendingJobs()
+	if err != nil {
+		log.Err(err).Msg("Failed to load pending install jobs")
+		return 0
+	}
+	sortQueue(jobs, time.Now())
+
+	var next time.Duration
+	for _, job := range jobs {
+		if t.pool.full() || t.shuttingDown.Load() {
+			break
+		}
+
+		now := time.Now()
+		ok, wait := t.pool.reserve(job, now)
+		if !ok {
+			if wait > 0 && (next == 0 || wait < next) {
+				next = wait
+			}
+			continue
+		}
+
+		claimed, err := service.ClaimJob(&job)
+		if err != nil || !claimed {
+			if err != nil {
+				log.Err(err).Msgf("Failed to start install job: %s", job.App.IpaName)
+			}
+			t.pool.release(job, 0, func() {})
+			continue
+		}
+		t.pool.started(job, now)
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		t.runningJobs.Store(job.ID, cancel)
+		t.jobsWG.Add(1)
+		go func(job model.Job) {
+			defer t.jobsWG.Done()
+			defer t.pool.release(job, deviceCooldown, t.wakeQueue)
+			defer t.runningJobs.Delete(job.ID)
+			defer cancel(nil)
+			t.runJob(ctx, job)
+		}(job)
+	}
+	return next
+}
@@ -0,0 +1,65 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func newPoolTestJob(udid, account string) model.Job {
+	return model.Job{App: model.InstalledApp{UDID: udid, Account: account}}
+}
+
+func TestWorkerPoolReserve(t *testing.T) {
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Task.MaxConcurrency = 2
+	app.Settings.Task.AccountInterval = 30
+
+	p := newWorkerPool()
+	now := time.Now()
+
+	if ok, _ := p.reserve(newPoolTestJob("tv1", "a@example.com"), now); !ok {
+		t.Fatal("first job should start")
+	}
+	p.started(newPoolTestJob("tv1", "a@example.com"), now)
+	if ok, _ := p.reserve(newPoolTestJob("tv1", "b@example.com"), now); ok {
+		t.Fatal("jobs for the same device should run one after another")
+	}
+	ok, wait := p.reserve(newPoolTestJob("tv2", "a@example.com"), now.Add(10*time.Second))
+	if ok || wait != 20*time.Second {
+		t.Fatalf("same account should be throttled for 20s, got ok=%v wait=%s", ok, wait)
+	}
+	if ok, _ := p.reserve(newPoolTestJob("tv2", "b@example.com"), now); !ok {
+		t.Fatal("another device with another account should start in parallel")
+	}
+	if ok, _ := p.reserve(newPoolTestJob("tv3", "c@example.com"), now); ok {
+		t.Fatal("global concurrency limit should be respected")
+	}
+
+	p.release(newPoolTestJob("tv1", "a@example.com"), 0, func() {})
+	if ok, _ := p.reserve(newPoolTestJob("tv1", "c@example.com"), now); !ok {
+		t.Fatal("released device should accept the next job")
+	}
+}
+
+func TestWorkerPoolUnclaimedJob(t *testing.T) {
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Task.MaxConcurrency = 2
+	app.Settings.Task.AccountInterval = 30
+
+	p := newWorkerPool()
+	now := time.Now()
+
+	// a job reserved but not claimed, e.g. cancelled meanwhile
+	job := newPoolTestJob("tv1", "a@example.com")
+	if ok, _ := p.reserve(job, now); !ok {
+		t.Fatal("job should get a slot")
+	}
+	p.release(job, 0, func() {})
+
+	if ok, wait := p.reserve(newPoolTestJob("tv2", "a@example.com"), now); !ok {
+		t.Fatalf("account should not be throttled by an unclaimed job, got wait=%s", wait)
+	}
+}
@@ -0,0 +1,122 @@
+package task
+
+import (
+	"sort"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+const (
+	// apps expiring within this window are refreshed before other scheduled jobs
+	expiringSoonWindow = 24 * time.Hour
+	// used to estimate the wait before any attempt has finished
+	defaultJobDuration = 3 * time.Minute
+	// number of recent attempts averaged to estimate the job duration
+	durationSamples = 20
+)
+
+// QueueEntry is a job in the install queue with its estimated start.
+type QueueEntry struct {
+	Position      int               `json:"position"`
+	Priority      model.JobPriority `json:"priority"`
+	Lane          string            `json:"lane"`
+	EstimatedWait int64             `json:"estimated_wait"`
+	EstimatedAt   time.Time         `json:"estimated_at"`
+	Job           model.Job         `json:"job"`
+}
+
+// enqueuePriority is the priority of a new job: requests from a user run
+// before everything else, scheduled ones first if the app is about to expire.
+func enqueuePriority(trigger model.RefreshTrigger, v model.InstalledApp, now time.Time) model.JobPriority {
+	if trigger == model.RefreshTriggerManual || trigger == model.RefreshTriggerMCP {
+		return model.JobPriorityInteractive
+	}
+	return jobPriority(model.Job{App: v}, now)
+}
+
+// jobPriority is the priority of a queued job, a scheduled job is promoted
+// once its app is about to expire.
+func jobPriority(job model.Job, now time.Time) model.JobPriority {
+	if job.Priority < model.JobPriorityExpiringSoon && job.App.ExpirationDate != nil && job.App.ExpirationDate.Sub(now) < expiringSoonWindow {
+		return model.JobPriorityExpiringSoon
+	}
+	return job.Priority
+}
+
+// sortQueue orders pending jobs by priority. Within a lane, retries go first,
+// the ones whose app expires soonest before the others, then oldest first.
+func sortQueue(jobs []model.Job, now time.Time) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		pi, pj := jobPriority(jobs[i], now), jobPriority(jobs[j], now)
+		if pi != pj {
+			return pi > pj
+		}
+		ri, rj := jobs[i].Attempts > 0, jobs[j].Attempts > 0
+		if ri != rj {
+			return ri
+		}
+		if ri {
+			return expiresBefore(jobs[i], jobs[j])
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+}
+
+// estimateQueue assigns positions and estimated waits to pending jobs, which
+// must be in queue order. running is the number of jobs already running.
+func estimateQueue(jobs []model.Job, now time.Time, running int, workers int, avg time.Duration) []QueueEntry {
+	if workers < 1 {
+		workers = 1
+	}
+	free := max(workers-running, 0)
+
+	entries := make([]QueueEntry, 0, len(jobs))
+	for i, job := range jobs {
+		var wait time.Duration
+		if i >= free {
+			wait = time.Duration((i-free)/workers+1) * avg
+		}
+		if job.NextRunAt != nil {
+			wait = max(wait, job.NextRunAt.Sub(now))
+		}
+		priority := jobPriority(job, now)
+		entries = append(entries, QueueEntry{
+			Position:      i + 1,
+			Priority:      priority,
+			Lane:          priority.String(),
+			EstimatedWait: int64(wait.Seconds()),
+			EstimatedAt:   now.Add(wait),
+			Job:           job,
+		})
+	}
+	return entries
+}
+
+// GetQueue returns the running jobs followed by the pending ones in the
+// order they will run, with an estimate of when each starts.
+func GetQueue() (running []model.Job, pending []QueueEntry, err error) {
+	jobs, err := service.GetActiveJobs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	running = make([]model.Job, 0)
+	waiting := make([]model.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if job.State.IsRunning() {
+			running = append(running, job)
+		} else {
+			waiting = append(waiting, job)
+		}
+	}
+	sortQueue(waiting, now)
+
+	avg := defaultJobDuration
+	if v, err := service.AverageAttemptDuration(durationSamples); err == nil && v > 0 {
+		avg = v
+	}
+	return running, estimateQueue(waiting, now, len(running), maxConcurrency(), avg), nil
+}
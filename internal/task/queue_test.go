@@ -0,0 +1,69 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestSortQueueByPriority(t *testing.T) {
+	now := time.Now()
+	soon := now.Add(6 * time.Hour)
+	later := now.Add(5 * 24 * time.Hour)
+
+	jobs := []model.Job{
+		{ID: 1, Priority: model.JobPriorityScheduled, App: model.InstalledApp{ExpirationDate: &later}},
+		{ID: 2, Priority: model.JobPriorityScheduled, App: model.InstalledApp{ExpirationDate: &soon}},
+		{ID: 3, Priority: model.JobPriorityScheduled, App: model.InstalledApp{ExpirationDate: &later}, Attempts: 1},
+		{ID: 4, Priority: model.JobPriorityInteractive, App: model.InstalledApp{ExpirationDate: &later}},
+		{ID: 5, Priority: model.JobPriorityScheduled, App: model.InstalledApp{ExpirationDate: &later}},
+	}
+	sortQueue(jobs, now)
+
+	want := []uint{4, 2, 3, 1, 5}
+	for i, job := range jobs {
+		if job.ID != want[i] {
+			t.Fatalf("position %d: got job %d, want %d", i, job.ID, want[i])
+		}
+	}
+}
+
+func TestEnqueuePriority(t *testing.T) {
+	now := time.Now()
+	soon := now.Add(time.Hour)
+	later := now.Add(72 * time.Hour)
+
+	cases := []struct {
+		trigger    model.RefreshTrigger
+		expiration *time.Time
+		want       model.JobPriority
+	}{
+		{model.RefreshTriggerManual, &later, model.JobPriorityInteractive},
+		{model.RefreshTriggerMCP, nil, model.JobPriorityInteractive},
+		{model.RefreshTriggerCron, &soon, model.JobPriorityExpiringSoon},
+		{model.RefreshTriggerDeviceConnected, &later, model.JobPriorityScheduled},
+		{model.RefreshTriggerCron, nil, model.JobPriorityScheduled},
+	}
+	for _, c := range cases {
+		got := enqueuePriority(c.trigger, model.InstalledApp{ExpirationDate: c.expiration}, now)
+		if got != c.want {
+			t.Errorf("enqueuePriority(%s) = %s, want %s", c.trigger, got, c.want)
+		}
+	}
+}
+
+func TestEstimateQueue(t *testing.T) {
+	now := time.Now()
+	retryAt := now.Add(30 * time.Minute)
+	jobs := []model.Job{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4, NextRunAt: &retryAt}}
+
+	// one of two workers busy: the first job starts now, then one wave per two jobs
+	entries := estimateQueue(jobs, now, 1, 2, 5*time.Minute)
+	want := []int64{0, 300, 300, 1800}
+	for i, entry := range entries {
+		if entry.Position != i+1 || entry.EstimatedWait != want[i] {
+			t.Errorf("entry %d: position %d wait %d, want position %d wait %d", i, entry.Position, entry.EstimatedWait, i+1, want[i])
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package task
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+// retryLimits is the maximum number of attempts per failure code, including
+// the first one. Failures that need user action are never retried, device
+// connection (AFC/mux) errors are usually transient.
+var retryLimits = map[model.FailureCode]int{
+	model.FailureAccountInvalid:     1,
+	model.FailureTwoFactorRequired:  1,
+	model.FailureAppNotFound:        1,
+	model.FailureInvalidIPA:         1,
+	model.FailureAppIDLimit:         1,
+	model.FailureActiveAppLimit:     1,
+	model.FailureMaxCertificates:    1,
+	model.FailurePairingInvalid:     1,
+	model.FailureDeviceDiskFull:     1,
+	model.FailureCertificateRevoked: 2,
+	model.FailureTimeout:            2,
+	model.FailureAnisette:           3,
+	model.FailureDownload:           3,
+	model.FailureUnknown:            3,
+	model.FailureAfcError:           5,
+	model.FailureDeviceOffline:      5,
+}
+
+const (
+	retryBaseDelay = 2 * time.Minute
+	retryMaxDelay  = time.Hour
+	// keep retrying at least this often when the app is about to expire
+	retryMinDelay = time.Minute
+)
+
+func classifyFailure(err error) model.FailureCode {
+	if err == nil || errors.Is(err, ErrJobCancelled) {
+		return ""
+	}
+	if errors.Is(err, ErrCodeExpired) {
+		return model.FailureTwoFactorRequired
+	}
+	var quotaErr *service.QuotaError
+	if errors.As(err, &quotaErr) {
+		if quotaErr.Kind == service.QuotaActiveApps {
+			return model.FailureActiveAppLimit
+		}
+		return model.FailureAppIDLimit
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "load app"):
+		return model.FailureAppNotFound
+	case strings.HasPrefix(msg, "failed to download ipa"):
+		return model.FailureDownload
+	case strings.HasPrefix(msg, "failed to parse ipa"):
+		return model.FailureInvalidIPA
+	}
+	return manager.FailureCodeOf(err)
+}
+
+// nextRetry returns when a job that failed with class on its attempts-th
+// attempt should run again, or false when it should not be retried.
+func nextRetry(class model.FailureCode, attempts int, expiration *time.Time, now time.Time) (time.Time, bool) {
+	limit, ok := retryLimits[class]
+	if !ok {
+		limit = retryLimits[model.FailureUnknown]
+	}
+	if attempts >= limit {
+		return time.Time{}, false
+	}
+
+	// exponential backoff with ±25% jitter
+	delay := retryBaseDelay << (attempts - 1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	delay = time.Duration(float64(delay) * (0.75 + rand.Float64()*0.5))
+
+	// spread the remaining attempts over the time left before expiration
+	if expiration != nil {
+		left := expiration.Sub(now) / time.Duration(limit-attempts+1)
+		if left < delay {
+			delay = left
+		}
+	}
+	if delay < retryMinDelay {
+		delay = retryMinDelay
+	}
+	return now.Add(delay), true
+}
+
+// expiresBefore orders jobs by the expiration of their app, the ones expiring
+// soonest first and apps without expiration last.
+func expiresBefore(a, b model.Job) bool {
+	ea, eb := a.App.ExpirationDate, b.App.ExpirationDate
+	switch {
+	case ea == nil && eb == nil:
+		return a.ID < b.ID
+	case ea == nil:
+		return false
+	case eb == nil:
+		return true
+	}
+	if ea.Equal(*eb) {
+		return a.ID < b.ID
+	}
+	return ea.Before(*eb)
+}
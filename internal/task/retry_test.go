@@ -0,0 +1,92 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	cases := map[string]model.FailureCode{
+		"afc service not available: exit status 1":   model.FailureAfcError,
+		"ERROR: AFC_E_MUX_ERROR while installing":    model.FailureAfcError,
+		"failed to download ipa: 404":                model.FailureDownload,
+		"failed to parse ipa file: zip: not a valid": model.FailureInvalidIPA,
+		"installation exceeded 60-minute timeout":    model.FailureTimeout,
+		"install failed with unknown error.":         model.FailureUnknown,
+	}
+	for msg, want := range cases {
+		if got := classifyFailure(errors.New(msg)); got != want {
+			t.Errorf("classifyFailure(%q) = %s, want %s", msg, got, want)
+		}
+	}
+
+	err := fmt.Errorf("login failed %w", manager.ErrAccountInvalid)
+	if got := classifyFailure(err); got != model.FailureAccountInvalid {
+		t.Errorf("classifyFailure(account invalid) = %s", got)
+	}
+
+	err = &manager.FailureError{Code: model.FailurePairingInvalid, Err: errors.New("exit status 1")}
+	if got := classifyFailure(fmt.Errorf("install: %w", err)); got != model.FailurePairingInvalid {
+		t.Errorf("classifyFailure(plumesign failure) = %s", got)
+	}
+	quotaErr := &service.QuotaError{Kind: service.QuotaActiveApps}
+	if got := classifyFailure(quotaErr); got != model.FailureActiveAppLimit {
+		t.Errorf("classifyFailure(quota) = %s", got)
+	}
+}
+
+func TestNextRetry(t *testing.T) {
+	now := time.Now()
+
+	if _, ok := nextRetry(model.FailureAccountInvalid, 1, nil, now); ok {
+		t.Fatal("account errors should not be retried")
+	}
+	if _, ok := nextRetry(model.FailureAfcError, retryLimits[model.FailureAfcError], nil, now); ok {
+		t.Fatal("retries should stop at the class limit")
+	}
+
+	first, ok := nextRetry(model.FailureAfcError, 1, nil, now)
+	if !ok {
+		t.Fatal("device errors should be retried")
+	}
+	if d := first.Sub(now); d < retryBaseDelay*3/4 || d > retryBaseDelay*5/4 {
+		t.Fatalf("first retry delay %s out of jitter range", d)
+	}
+	third, _ := nextRetry(model.FailureAfcError, 3, nil, now)
+	if d := third.Sub(now); d < 4*retryBaseDelay*3/4 {
+		t.Fatalf("third retry delay %s should back off exponentially", d)
+	}
+
+	// close to expiration the remaining attempts are spread over the time left
+	expiration := now.Add(20 * time.Minute)
+	soon, _ := nextRetry(model.FailureAfcError, 4, &expiration, now)
+	if d := soon.Sub(now); d > 10*time.Minute {
+		t.Fatalf("retry delay %s should shrink before expiration", d)
+	}
+}
+
+func TestFailureHints(t *testing.T) {
+	i18n.Init(os.DirFS("../.."))
+
+	// every code has a retry limit and a localized hint
+	for code := range retryLimits {
+		if code == model.FailureUnknown {
+			continue
+		}
+		if hint := failureHint(code); !strings.HasPrefix(hint, "Hint: ") || strings.Contains(hint, "failure.") {
+			t.Errorf("failureHint(%s) = %q", code, hint)
+		}
+	}
+	if hint := failureHint(model.FailureUnknown); hint != "" {
+		t.Errorf("unknown failures should have no hint, got %q", hint)
+	}
+}
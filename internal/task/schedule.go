@@ -0,0 +1,131 @@
+package task
+
+import (
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/robfig/cron/v3"
+)
+
+// addAppSchedules registers a cron entry for every app with its own refresh
+// schedule.
+func (t *Task) addAppSchedules() {
+	apps, err := service.GetEnableAppList()
+	if err != nil {
+		log.Err(err).Msg("Failed to get the installation list")
+		return
+	}
+
+	for _, v := range apps {
+		if v.RefreshCron == "" {
+			continue
+		}
+		id := v.ID
+		if _, err := t.c.AddFunc(v.RefreshCron, func() { t.runAppSchedule(id) }); err != nil {
+			log.Err(err).Msgf("Invalid refresh schedule of app %s, fall back to the global schedule: %s", v.IpaName, v.RefreshCron)
+		}
+	}
+}
+
+func (t *Task) runAppSchedule(id uint) {
+	v, err := service.GetApp(id)
+	if err != nil || !v.Enabled {
+		return
+	}
+	if !t.canScheduleRefresh(*v) {
+		return
+	}
+
+	log.Infof("Start executing scheduled refresh of app: %s", v.IpaName)
+	t.StartInstallApps([]model.InstalledApp{*v}, true, model.RefreshTriggerCron)
+}
+
+// canScheduleRefresh reports whether a scheduled run should refresh the app.
+func (t *Task) canScheduleRefresh(v model.InstalledApp) bool {
+	reason := skipReason(v, time.Now(), app.Settings.Task.AdvanceDays, checkAfc)
+	if reason == SkipAccountInvalid {
+		log.Warnf("The install account (%s) is invalid, skip refresh app: %s.", v.MaskAccount(), v.IpaName)
+	}
+	return reason == ""
+}
+
+// skipReason returns why a scheduled run at the given time skips the app, or
+// an empty reason when the app is refreshed.
+func skipReason(v model.InstalledApp, at time.Time, advanceDays int, device func(model.InstalledApp) SkipReason) SkipReason {
+	if due := v.RefreshDueTime(advanceDays); due != nil && !due.Before(at) {
+		return SkipNotDue
+	}
+
+	if v.IsAccountInvalid() {
+		return SkipAccountInvalid
+	}
+	return device(v)
+}
+
+// checkDevice returns why the device of the app cannot be refreshed now. Only
+// the preview reports offline devices, a scheduled run still queues their
+// apps so the failure is retried and notified.
+func checkDevice(v model.InstalledApp) SkipReason {
+	if _, found := manager.GetDeviceByUDID(v.UDID); !found {
+		return SkipDeviceOffline
+	}
+	return checkAfc(v)
+}
+
+// checkAfc returns SkipAfcUnavailable for an iPhone app whose phone is locked.
+func checkAfc(v model.InstalledApp) SkipReason {
+	// iPhone cannot refresh on a schedule and relies on whether the phone is unlocked
+	// Need to check Afc service status before refreshing
+	if v.IsIPhoneApp() {
+		if err := manager.CheckAfcServiceStatus(v.UDID); err != nil {
+			return SkipAfcUnavailable
+		}
+	}
+	return ""
+}
+
+// ValidateSchedule checks a cron expression for an app refresh schedule.
+func ValidateSchedule(expr string) error {
+	_, err := cron.ParseStandard(expr)
+	return err
+}
+
+// NextPlannedRun returns the first firing of the app's schedule, or the global
+// one, at which the app will be due for a refresh. It returns nil when
+// scheduled refresh is disabled.
+func NextPlannedRun(v model.InstalledApp, now time.Time) *time.Time {
+	if !app.Settings.Task.Enabled || !v.Enabled {
+		return nil
+	}
+
+	expr := app.Settings.Task.CrodTime
+	if v.RefreshCron != "" && ValidateSchedule(v.RefreshCron) == nil {
+		expr = v.RefreshCron
+	}
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil
+	}
+
+	from := now
+	if due := v.RefreshDueTime(app.Settings.Task.AdvanceDays); due != nil && due.After(now) {
+		from = *due
+	}
+	next := schedule.Next(from)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// SetNextPlannedRuns fills NextRefreshAt of apps.
+func SetNextPlannedRuns(apps []model.InstalledApp) {
+	now := time.Now()
+	for i := range apps {
+		apps[i].NextRefreshAt = NextPlannedRun(apps[i], now)
+	}
+}
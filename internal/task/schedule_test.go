@@ -0,0 +1,48 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestNextPlannedRun(t *testing.T) {
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Task.Enabled = true
+	app.Settings.Task.CrodTime = "0 3 * * *"
+	app.Settings.Task.AdvanceDays = 1
+
+	now := time.Date(2026, 10, 5, 12, 0, 0, 0, time.Local) // Monday
+	refreshed := now.AddDate(0, 0, -1)
+	expiration := time.Date(2026, 10, 10, 12, 0, 0, 0, time.Local)
+	v := model.InstalledApp{Enabled: true, RefreshedDate: &refreshed, ExpirationDate: &expiration}
+
+	// due one day before expiration, first global run after that
+	want := time.Date(2026, 10, 10, 3, 0, 0, 0, time.Local)
+	if got := NextPlannedRun(v, now); got == nil || !got.Equal(want) {
+		t.Fatalf("NextPlannedRun = %v, want %v", got, want)
+	}
+
+	// three days early, on weekends only
+	v.AdvanceDays = 3
+	v.RefreshCron = "0 4 * * 6,0"
+	want = time.Date(2026, 10, 10, 4, 0, 0, 0, time.Local)
+	if got := NextPlannedRun(v, now); got == nil || !got.Equal(want) {
+		t.Fatalf("NextPlannedRun with overrides = %v, want %v", got, want)
+	}
+
+	// already due, next firing from now
+	v.RefreshCron = ""
+	v.AdvanceDays = 6
+	want = time.Date(2026, 10, 6, 3, 0, 0, 0, time.Local)
+	if got := NextPlannedRun(v, now); got == nil || !got.Equal(want) {
+		t.Fatalf("NextPlannedRun for due app = %v, want %v", got, want)
+	}
+
+	app.Settings.Task.Enabled = false
+	if got := NextPlannedRun(v, now); got != nil {
+		t.Fatalf("NextPlannedRun should be nil when scheduled refresh is disabled, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+// ErrShutdown is the cancel cause of jobs still running when the grace period
+// of a shutdown is over.
+var ErrShutdown = errors.New("interrupted by shutdown")
+
+// time left for interrupted jobs to kill plumesign and clean up
+const interruptTimeout = 10 * time.Second
+
+// Shutdown stops the schedules and the queue, then waits for the running jobs
+// until ctx is done. Jobs still running after that are interrupted and stay
+// queued, so they run again after the restart.
+func (t *Task) Shutdown(ctx context.Context) {
+	t.shuttingDown.Store(true)
+	if t.c != nil {
+		t.Stop()
+	}
+
+	if t.waitJobs(ctx) {
+		log.Info("All install jobs finished.")
+		return
+	}
+
+	log.Warn("Shutdown grace period is over, interrupt running install jobs.")
+	t.runningJobs.Range(func(_, cancel any) bool {
+		cancel.(context.CancelCauseFunc)(ErrShutdown)
+		return true
+	})
+	interruptCtx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+	defer cancel()
+	if !t.waitJobs(interruptCtx) {
+		log.Warn("Install jobs did not stop in time.")
+	}
+}
+
+// waitJobs reports whether the running jobs finished before ctx is done.
+func (t *Task) waitJobs(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		t.jobsWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// interruptJob checkpoints a job stopped by shutdown. The job is left running,
+// it is requeued by resumeJobs on the next start.
+func (t *Task) interruptJob(item TaskItem, attempt *model.RefreshAttempt) {
+	log.Infof("Installing ipa interrupted by shutdown: %s", item.App.IpaName)
+	if attempt == nil {
+		return
+	}
+	if attempt.AppID == 0 {
+		attempt.AppID = item.App.ID
+	}
+	attempt.Outcome = model.AttemptOutcomeInterrupted
+	attempt.Error = ErrShutdown.Error()
+	if err := service.FinishAttempt(attempt); err != nil {
+		log.Err(err).Msgf("Failed to save refresh attempt: %s", item.App.IpaName)
+	}
+}
+
+func Shutdown(ctx context.Context) {
+	instance.Shutdown(ctx)
+}
@@ -0,0 +1,44 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownInterruptsRunningJobs(t *testing.T) {
+	tk := new()
+
+	// a job finishing within the grace period
+	tk.jobsWG.Add(1)
+	go func() {
+		defer tk.jobsWG.Done()
+		time.Sleep(10 * time.Millisecond)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !tk.waitJobs(ctx) {
+		t.Fatal("waitJobs should report finished jobs")
+	}
+
+	// a job running until it is cancelled
+	jobCtx, jobCancel := context.WithCancelCause(context.Background())
+	tk.runningJobs.Store(uint(1), jobCancel)
+	tk.jobsWG.Add(1)
+	go func() {
+		defer tk.jobsWG.Done()
+		<-jobCtx.Done()
+	}()
+
+	graceCtx, graceCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer graceCancel()
+	tk.Shutdown(graceCtx)
+
+	if !errors.Is(context.Cause(jobCtx), ErrShutdown) {
+		t.Fatalf("job cancel cause = %v, want %v", context.Cause(jobCtx), ErrShutdown)
+	}
+	if !tk.shuttingDown.Load() {
+		t.Fatal("task should be marked as shutting down")
+	}
+}
@@ -6,15 +6,14 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitxeno/atvloadly/internal/app"
-	"github.com/bitxeno/atvloadly/internal/i18n"
 	"github.com/bitxeno/atvloadly/internal/ipa"
 	"github.com/bitxeno/atvloadly/internal/log"
 	"github.com/bitxeno/atvloadly/internal/manager"
 	"github.com/bitxeno/atvloadly/internal/model"
-	"github.com/bitxeno/atvloadly/internal/notify"
 	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/robfig/cron/v3"
 )
@@ -22,42 +21,47 @@ import (
 var instance = new()
 
 type Task struct {
-	c               *cron.Cron
-	InstallingApps  sync.Map
-	InstallAppQueue chan TaskItem
+	c *cron.Cron
+	// digestCron sends the daily digest, nil when it is off
+	digestCron *cron.Cron
+	// InstallingApps mirrors the pending and running jobs, keyed by job ID
+	InstallingApps sync.Map
+	// runningJobs holds the cancel func of each running job, keyed by job ID
+	runningJobs     sync.Map
+	jobsWG          sync.WaitGroup
+	shuttingDown    atomic.Bool
+	chWakeQueue     chan struct{}
 	chExitQueue     chan bool
+	chExitWatchdog  chan bool
+	resumeOnce      sync.Once
+	pool            *workerPool
+	invalidMu       sync.Mutex
 	InvalidAccounts map[string]bool
 	// RefreshingDevices prevents concurrent refresh operations for the same device UDID
 	RefreshingDevices sync.Map
-	// Batch tracking for aggregated notifications
-	batchMu      sync.Mutex
-	currentBatch *BatchInfo
+	// Batch tracking for aggregated notifications, keyed by batch ID
+	batchMu sync.Mutex
+	batches map[string]*BatchInfo
+	// codePrompts holds the jobs waiting for a 2FA code, keyed by job ID
+	codePrompts sync.Map
 }
 
 type TaskItem struct {
-	App     model.InstalledApp
-	Notify  bool
-	BatchID string
-}
-
-type BatchInfo struct {
-	ID           string
-	TotalCount   int
-	SuccessCount int
-	FailedApps   []FailedAppInfo
-	Notify       bool
-}
-
-type FailedAppInfo struct {
-	AppName string
-	Account string
-	Error   string
+	JobID     uint
+	App       model.InstalledApp
+	Notify    bool
+	BatchID   string
+	Reinstall bool
 }
 
 func new() *Task {
 	return &Task{
-		InstallAppQueue: make(chan TaskItem, 100),
+		chWakeQueue:     make(chan struct{}, 1),
 		chExitQueue:     make(chan bool, 1),
+		chExitWatchdog:  make(chan bool, 1),
+		pool:            newWorkerPool(),
+		InvalidAccounts: make(map[string]bool),
+		batches:         make(map[string]*BatchInfo),
 	}
 }
 
@@ -72,6 +76,8 @@ func (t *Task) RunSchedule() error {
 		t.c = nil
 		return err
 	}
+	t.addAppSchedules()
+	t.addDigestSchedule()
 
 	t.Start()
 
@@ -85,6 +91,9 @@ func (t *Task) Start() {
 	} else {
 		log.Warn("App refresh scheduled task is disabled.")
 	}
+	if t.digestCron != nil {
+		t.digestCron.Start()
+	}
 
 	// Register device connection callback to automatically refresh the application when the device is connected
 	manager.SetDeviceConnectedCallback(func(device model.Device) {
@@ -93,13 +102,20 @@ func (t *Task) Start() {
 		}
 	})
 
+	t.resumeOnce.Do(t.resumeJobs)
 	go t.runQueue()
+	go t.runWatchdog()
 }
 
 func (t *Task) Stop() {
 	t.chExitQueue <- true
+	t.chExitWatchdog <- true
 	<-t.c.Stop().Done()
 	t.c = nil
+	if t.digestCron != nil {
+		<-t.digestCron.Stop().Done()
+		t.digestCron = nil
+	}
 }
 
 func (t *Task) Run() {
@@ -111,23 +127,15 @@ func (t *Task) Run() {
 
 	appsNeedRefresh := make([]model.InstalledApp, 0)
 	for _, v := range installedApps {
-		if !v.NeedRefresh(app.Settings.Task.AdvanceDays) {
+		// apps with their own schedule are refreshed by runAppSchedule, an
+		// invalid one falls back to the global schedule
+		if v.RefreshCron != "" && ValidateSchedule(v.RefreshCron) == nil {
 			continue
 		}
-
-		if v.IsAccountInvalid() {
-			log.Warnf("The install account (%s) is invalid, skip refresh app: %s.", v.MaskAccount(), v.IpaName)
+		if !t.canScheduleRefresh(v) {
 			continue
 		}
 
-		// iPhone cannot refresh on a schedule and relies on whether the phone is unlocked
-		// Need to check Afc service status before refreshing
-		if v.IsIPhoneApp() {
-			if err := manager.CheckAfcServiceStatus(v.UDID); err != nil {
-				continue
-			}
-		}
-
 		appsNeedRefresh = append(appsNeedRefresh, v)
 	}
 
@@ -137,80 +145,267 @@ func (t *Task) Run() {
 	}
 
 	log.Infof("Start executing installation task (%d need refresh)...", len(appsNeedRefresh))
-	t.StartInstallApps(appsNeedRefresh, true)
+	t.StartInstallApps(appsNeedRefresh, true, model.RefreshTriggerCron)
+}
+
+func (t *Task) StartInstallApps(apps []model.InstalledApp, notify bool, trigger model.RefreshTrigger) {
+	t.enqueueApps(apps, notify, trigger, false)
+}
+
+// ReinstallApp queues an install of the saved IPA of v that replaces the
+// installed app rather than refreshing it.
+func (t *Task) ReinstallApp(v model.InstalledApp, trigger model.RefreshTrigger) {
+	t.enqueueApps([]model.InstalledApp{v}, true, trigger, true)
 }
 
-func (t *Task) StartInstallApps(apps []model.InstalledApp, notify bool) {
+func (t *Task) enqueueApps(apps []model.InstalledApp, notify bool, trigger model.RefreshTrigger, reinstall bool) {
 	t.resetInvalidAccounts()
 
 	if len(apps) == 0 {
 		return
 	}
 
-	// Create a batch for aggregated notification
 	batchID := fmt.Sprintf("batch-%d", time.Now().UnixNano())
-	t.batchMu.Lock()
-	t.currentBatch = &BatchInfo{
-		ID:           batchID,
-		TotalCount:   len(apps),
-		SuccessCount: 0,
-		FailedApps:   make([]FailedAppInfo, 0),
-		Notify:       notify,
+	jobs := make([]model.Job, 0, len(apps))
+	now := time.Now()
+	for _, v := range apps {
+		priority := enqueuePriority(trigger, v, now)
+		if t.isQueued(v, jobs, priority) {
+			log.Infof("The app is already queued, skip task: %s", v.IpaName)
+			continue
+		}
+		jobs = append(jobs, model.Job{BatchID: batchID, AppID: v.ID, App: v, Notify: notify, Trigger: trigger, Priority: priority, Reinstall: reinstall})
+	}
+	if len(jobs) == 0 {
+		return
 	}
-	t.batchMu.Unlock()
 
-	for _, v := range apps {
-		t.startInstallAppInternal(v, notify, batchID)
+	jobs, err := service.EnqueueJobs(jobs)
+	if err != nil {
+		log.Err(err).Msg("Failed to save install jobs")
+		return
+	}
+
+	// Create a batch for aggregated notification
+	t.addBatch(&BatchInfo{
+		ID:         batchID,
+		Trigger:    trigger,
+		StartedAt:  time.Now(),
+		TotalCount: len(jobs),
+		FailedApps: make([]FailedAppInfo, 0),
+		Notify:     notify,
+	})
+
+	for _, job := range jobs {
+		t.InstallingApps.Store(job.ID, job.App)
 	}
+	t.wakeQueue()
 }
 
-func (t *Task) startInstallAppInternal(v model.InstalledApp, notify bool, batchID string) {
-	if _, loaded := t.InstallingApps.LoadOrStore(v.ID, v); !loaded {
-		select {
-		case t.InstallAppQueue <- TaskItem{App: v, Notify: notify, BatchID: batchID}:
-		default:
-			t.InstallingApps.Delete(v.ID)
-			log.Warnf("The install queue is full, skip task: %s", v.IpaName)
+// isQueued reports whether an installed app already has an active job, either
+// stored or about to be stored with jobs.
+func (t *Task) isQueued(v model.InstalledApp, jobs []model.Job, priority model.JobPriority) bool {
+	if v.ID == 0 {
+		return false
+	}
+	for _, job := range jobs {
+		if job.AppID == v.ID {
+			return true
 		}
 	}
+	active, err := service.HasActiveJob(v.ID)
+	if err != nil {
+		log.Err(err).Msgf("Failed to check queued jobs: %s", v.IpaName)
+	}
+	if active {
+		// a new request for the app runs its pending retry right away
+		if err := service.ExpediteJob(v.ID, priority); err != nil {
+			log.Err(err).Msgf("Failed to expedite queued job: %s", v.IpaName)
+		}
+		t.wakeQueue()
+	}
+	return active
 }
 
-func (t *Task) runQueue() {
-	// Wait for one minute before install at startup to avoid the usbmuxd service not being ready.
-	manager.Usbmuxd().TryWaitReady(30 * time.Second)
+// resumeJobs requeues jobs left pending or running by the previous process
+// and restores the batch they belong to.
+func (t *Task) resumeJobs() {
+	if err := service.CleanFinishedJobs(time.Now().AddDate(0, 0, -30)); err != nil {
+		log.Err(err).Msg("Failed to clean finished jobs")
+	}
+	if err := service.CleanRefreshAttempts(time.Now().AddDate(-1, 0, 0)); err != nil {
+		log.Err(err).Msg("Failed to clean refresh history")
+	}
 
-	for {
-		select {
-		case v := <-t.InstallAppQueue:
-			t.tryInstallApp(v)
-			t.InstallingApps.Delete(v.App.ID)
+	interrupted, err := service.ResetRunningJobs()
+	if err != nil {
+		log.Err(err).Msg("Failed to reset interrupted jobs")
+		return
+	}
+	jobs, err := service.GetActiveJobs()
+	if err != nil {
+		log.Err(err).Msg("Failed to load queued jobs")
+		return
+	}
+	if len(jobs) == 0 {
+		return
+	}
 
-			// Next execution delayed by 10 seconds.
-			time.Sleep(10 * time.Second)
-		case <-t.chExitQueue:
-			log.Info("Install app queue exit.")
-			return
+	for _, job := range jobs {
+		t.InstallingApps.Store(job.ID, job.App)
+	}
+	restored := make(map[string]bool)
+	for _, job := range jobs {
+		if !restored[job.BatchID] {
+			restored[job.BatchID] = true
+			t.restoreBatch(job.BatchID)
 		}
 	}
+	log.Infof("Resume %d queued install jobs (%d interrupted).", len(jobs), interrupted)
+	t.wakeQueue()
 }
 
-func (t *Task) tryInstallApp(item TaskItem) {
+func (t *Task) wakeQueue() {
+	select {
+	case t.chWakeQueue <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Task) runJob(ctx context.Context, job model.Job) {
+	item := TaskItem{JobID: job.ID, App: job.App, Notify: job.Notify, BatchID: job.BatchID, Reinstall: job.Reinstall}
+	attempt, attemptErr := service.StartAttempt(job)
+	if attemptErr != nil {
+		log.Err(attemptErr).Msgf("Failed to save refresh attempt: %s", item.App.IpaName)
+	}
+
+	var err error
+	if job.AppID != 0 {
+		// refresh with the latest saved app, it may have been changed or deleted since queued
+		var cur *model.InstalledApp
+		if cur, err = service.GetApp(job.AppID); err == nil {
+			item.App = *cur
+		} else {
+			err = fmt.Errorf("load app %d failed: %w", job.AppID, err)
+		}
+	}
+	if err == nil {
+		err = t.tryInstallApp(ctx, item, attempt)
+	}
+
+	cancelled := err != nil && ctx.Err() != nil && !errors.Is(err, ErrCodeExpired)
+	if cancelled && errors.Is(context.Cause(ctx), ErrShutdown) {
+		t.interruptJob(item, attempt)
+		return
+	}
+	if cancelled {
+		err = ErrJobCancelled
+	}
+	class := classifyFailure(err)
+	retryAt, retry := time.Time{}, false
+	var quotaErr *service.QuotaError
+	if errors.As(err, &quotaErr) && quotaErr.RetryAt != nil {
+		// defer until the oldest App ID of the account expires
+		retryAt, retry = *quotaErr.RetryAt, true
+	} else if err != nil && !cancelled {
+		retryAt, retry = nextRetry(class, job.Attempts, item.App.ExpirationDate, time.Now())
+	}
+
+	if attempt != nil {
+		if attempt.AppID == 0 {
+			attempt.AppID = item.App.ID
+		}
+		attempt.Outcome = model.AttemptOutcomeSucceeded
+		if cancelled {
+			attempt.Outcome = model.AttemptOutcomeCancelled
+		} else if err != nil {
+			attempt.Outcome = model.AttemptOutcomeFailed
+			attempt.ErrorClass = string(class)
+			attempt.Error = err.Error()
+			if retry {
+				attempt.NextRetryAt = &retryAt
+			}
+		}
+		if saveErr := service.FinishAttempt(attempt); saveErr != nil {
+			log.Err(saveErr).Msgf("Failed to save refresh attempt: %s", item.App.IpaName)
+		}
+	}
+
+	if retry {
+		log.Infof("Retry installing ipa at %s (attempt %d, %s): %s", retryAt.Format(time.DateTime), job.Attempts+1, class, item.App.IpaName)
+		if saveErr := service.RetryJob(job.ID, retryAt, string(class), err.Error()); saveErr != nil {
+			log.Err(saveErr).Msgf("Failed to save install job result: %s", item.App.IpaName)
+		}
+		return
+	}
+
+	defer t.InstallingApps.Delete(job.ID)
+	state, errClass, errMsg := model.JobStateSucceeded, "", ""
+	if cancelled {
+		state, errMsg = model.JobStateCancelled, err.Error()
+		log.Infof("Installing ipa cancelled: %s", item.App.IpaName)
+	} else if err != nil {
+		state, errClass, errMsg = model.JobStateFailed, string(class), err.Error()
+	}
+	if saveErr := service.FinishJob(job.ID, state, errClass, errMsg); saveErr != nil {
+		log.Err(saveErr).Msgf("Failed to save install job result: %s", item.App.IpaName)
+	}
+
+	// Track batch progress and send aggregated notification
+	t.trackBatchProgress(item, err == nil, err)
+}
+
+// tryInstallApp installs the app of item. The log and provisioning profile of
+// the run are recorded on attempt when it is not nil.
+func (t *Task) tryInstallApp(ctx context.Context, item TaskItem, attempt *model.RefreshAttempt) error {
 	resolvedApp, err := t.resolveIPA(item.App)
 	if err != nil {
 		log.Err(err).Msgf("Prepare ipa path failed: %s", item.App.IpaName)
 		t.handleInstallFailure(item, item.App, err)
-		return
+		return err
 	}
 	v := *resolvedApp
 
 	log.Infof("Start installing ipa: %s", v.IpaName)
 	installMgr := manager.NewInstallManager()
+	installMgr.OnProgress(func(progress model.InstallProgress) {
+		if item.JobID == 0 {
+			return
+		}
+		if err := service.UpdateJobProgress(item.JobID, progress); err != nil {
+			log.Err(err).Msgf("Failed to save install progress: %s", v.IpaName)
+		}
+	})
+	installMgr.OnTwoFactor(func() {
+		// interactive installs get the code from the websocket
+		if item.JobID == 0 {
+			return
+		}
+		t.awaitCode(item, installMgr)
+	})
 	defer func() {
+		t.clearCode(item.JobID)
 		installMgr.SaveLog(v.ID)
+		if attempt != nil {
+			// new installs only get their app ID once saved
+			attempt.AppID = v.ID
+			installMgr.SaveAttemptLog(attempt.ID)
+			if installMgr.ProvisioningProfile != nil {
+				attempt.ProfileUUID = installMgr.ProvisioningProfile.UUID
+			}
+		}
 		installMgr.CleanTempFiles(v.IpaPath)
 		installMgr.Close()
 	}()
-	provisioningProfile, err := t.runInternal(v, installMgr)
+	if err := service.CheckQuota(v, time.Now()); err != nil {
+		log.Warnf("Installing ipa refused: %s, %s", v.IpaName, err.Error())
+		return err
+	}
+	provisioningProfile, err := t.runInternal(ctx, v, installMgr, !item.Reinstall && shouldUseRefreshMode(v))
+	if err != nil && errors.Is(context.Cause(ctx), ErrCodeExpired) {
+		// killed for the missing 2FA code, a failure rather than a cancel
+		err = ErrCodeExpired
+	}
 
 	success := err == nil
 	if success {
@@ -224,13 +419,14 @@ func (t *Task) tryInstallApp(item TaskItem) {
 		v.ExpirationDate = &expirationDate
 		v.RefreshedResult = true
 		v.RefreshedError = model.RefreshedErrorNone
+		v.FailureCode = ""
 
 		if v.ID == 0 {
 			savedApp, saveErr := service.SaveApp(v)
 			if saveErr != nil {
 				log.Err(saveErr).Msgf("Save app failed after installation success: %s", v.IpaName)
 				t.handleInstallFailure(item, v, saveErr)
-				return
+				return saveErr
 			}
 			v = *savedApp
 		} else {
@@ -238,21 +434,26 @@ func (t *Task) tryInstallApp(item TaskItem) {
 				log.Err(updateErr).Msgf("Update app refresh result failed: %s", v.IpaName)
 			}
 		}
+		if recordErr := service.RecordAppIDRegistration(v.Account, v.BundleIdentifier, now); recordErr != nil {
+			log.Err(recordErr).Msgf("Failed to record App ID registration: %s", v.IpaName)
+		}
 
 		log.Infof("Installing ipa success: %s", v.IpaName)
 	} else {
-		t.handleInstallFailure(item, v, err)
-		return
+		if ctx.Err() == nil || errors.Is(err, ErrCodeExpired) {
+			t.handleInstallFailure(item, v, err)
+		}
+		return err
 	}
 
-	// Track batch progress and send aggregated notification
-	t.trackBatchProgress(item, success, err)
+	return nil
 }
 
 func (t *Task) handleInstallFailure(item TaskItem, v model.InstalledApp, err error) {
 	log.Err(err).Msgf("Installing ipa failed: %s", v.IpaName)
 	v.RefreshedResult = false
-	if errors.Is(err, manager.ErrAccountInvalid) {
+	v.FailureCode = classifyFailure(err)
+	if v.FailureCode == model.FailureAccountInvalid {
 		v.RefreshedError = model.RefreshedErrorInvalidAccount
 	} else {
 		v.RefreshedError = model.RefreshedErrorInvalidOther
@@ -260,9 +461,6 @@ func (t *Task) handleInstallFailure(item TaskItem, v model.InstalledApp, err err
 	if v.ID != 0 {
 		_ = service.UpdateAppRefreshResult(v)
 	}
-
-	// Track batch progress and send aggregated notification
-	t.trackBatchProgress(item, false, err)
 }
 
 func (t *Task) resolveIPA(v model.InstalledApp) (*model.InstalledApp, error) {
@@ -291,60 +489,18 @@ func (t *Task) resolveIPA(v model.InstalledApp) (*model.InstalledApp, error) {
 		v.Version = result.Version
 		v.Icon = result.IconPath
 	}
+	v.ApplyOverrides()
 
 	return &v, nil
 }
 
-func (t *Task) trackBatchProgress(item TaskItem, success bool, err error) {
-	t.batchMu.Lock()
-	defer t.batchMu.Unlock()
-
-	if t.currentBatch == nil || t.currentBatch.ID != item.BatchID {
-		return
-	}
-
-	if success {
-		t.currentBatch.SuccessCount++
-	} else {
-		t.currentBatch.FailedApps = append(t.currentBatch.FailedApps, FailedAppInfo{
-			AppName: item.App.IpaName,
-			Account: item.App.Account,
-			Error:   err.Error(),
-		})
-	}
-
-	// Check if batch is complete
-	completedCount := t.currentBatch.SuccessCount + len(t.currentBatch.FailedApps)
-	if completedCount >= t.currentBatch.TotalCount {
-		// Batch complete, send aggregated notification
-		t.sendBatchNotification(t.currentBatch)
-		t.currentBatch = nil
-	}
-}
-
-func (t *Task) sendBatchNotification(batch *BatchInfo) {
-	if !batch.Notify || !app.Settings.Notification.Enabled {
-		return
-	}
-
-	if len(batch.FailedApps) > 0 {
-		// Some apps failed, send aggregated failure notification
-		var message strings.Builder
-		for _, failed := range batch.FailedApps {
-			message.WriteString(i18n.LocalizeF("notify.batch_content", map[string]any{"name": failed.AppName, "error": failed.Error}))
-		}
-		title := i18n.LocalizeF("notify.batch_title", map[string]any{})
-		_ = notify.Send(title, message.String())
-	}
-}
-
-func (t *Task) runInternal(v model.InstalledApp, installMgr *manager.InstallManager) (*model.MobileProvisioningProfile, error) {
+func (t *Task) runInternal(ctx context.Context, v model.InstalledApp, installMgr *manager.InstallManager, refreshMode bool) (*model.MobileProvisioningProfile, error) {
 	if v.Account == "" || v.UDID == "" {
 		installMgr.WriteLog("account or UDID is empty")
 		return nil, fmt.Errorf("%s", "account or UDID is empty")
 	}
 
-	if _, ok := t.InvalidAccounts[v.Account]; ok {
+	if t.isInvalidAccount(v.Account) {
 		log.Warnf("The install account (%s) is invalid, skip install app: %s.", v.MaskAccount(), v.IpaName)
 		installMgr.WriteLog(fmt.Sprintf("The install account (%s) is invalid, skip install.", v.MaskAccount()))
 		return nil, fmt.Errorf("the install account (%s) is invalid, skip install", v.MaskAccount())
@@ -355,22 +511,23 @@ func (t *Task) runInternal(v model.InstalledApp, installMgr *manager.InstallMana
 		return nil, fmt.Errorf("device not found for UDID: %s", v.UDID)
 	}
 
-	err := installMgr.TryStart(context.Background(), manager.InstallOptions{
+	err := installMgr.TryStart(ctx, manager.InstallOptions{
 		UDID:             v.UDID,
 		Account:          v.Account,
 		IP:               dev.IP,
 		Port:             dev.Port,
 		IpaPath:          v.IpaPath,
 		RemoveExtensions: v.RemoveExtensions,
-		RefreshMode:      shouldUseRefreshMode(v),
+		RefreshMode:      refreshMode,
+		Overrides:        manager.AppOverrides(v),
 	})
 	if err != nil {
 		installMgr.WriteLog(err.Error())
 		if errors.Is(err, manager.ErrAccountInvalid) {
-			t.InvalidAccounts[v.Account] = true
+			t.markInvalidAccount(v.Account)
 			return nil, err
 		}
-		return nil, fmt.Errorf("%s %s", installMgr.ErrorLog(), err.Error())
+		return nil, fmt.Errorf("%s %w", installMgr.ErrorLog(), err)
 	}
 
 	if installMgr.IsSuccess() {
@@ -391,11 +548,11 @@ func (t *Task) autoRefreshDeviceApps(device model.Device) error {
 	if !app.Settings.Task.Enabled || !app.Settings.Task.IphoneEnabled {
 		return nil
 	}
-	return t.refreshDeviceApps(device)
+	return t.refreshDeviceApps(device, model.RefreshTriggerDeviceConnected)
 }
 
 // refreshes apps when device is discovery on network, for iPhone only.
-func (t *Task) refreshDeviceApps(device model.Device) error {
+func (t *Task) refreshDeviceApps(device model.Device, trigger model.RefreshTrigger) error {
 	deviceApps, err := service.GetEnableAppListByUDID(device.UDID)
 	if err != nil {
 		return err
@@ -440,25 +597,44 @@ func (t *Task) refreshDeviceApps(device model.Device) error {
 		}
 
 		log.Infof("Start refresh apps for device: %s (found %d apps, %d need refresh)...", name, len(deviceApps), len(apps))
-		t.StartInstallApps(apps, false)
+		t.StartInstallApps(apps, false, trigger)
 	}(device.UDID, device.Name, appsNeedRefresh)
 	return nil
 }
 
 func (t *Task) resetInvalidAccounts() {
+	t.invalidMu.Lock()
+	defer t.invalidMu.Unlock()
 	t.InvalidAccounts = make(map[string]bool)
 }
 
+func (t *Task) isInvalidAccount(account string) bool {
+	t.invalidMu.Lock()
+	defer t.invalidMu.Unlock()
+	return t.InvalidAccounts[account]
+}
+
+func (t *Task) markInvalidAccount(account string) {
+	t.invalidMu.Lock()
+	defer t.invalidMu.Unlock()
+	t.InvalidAccounts[account] = true
+}
+
 func ScheduleRefreshApps() error {
 	return instance.RunSchedule()
 }
 
-func RefreshApp(v model.InstalledApp) {
-	instance.StartInstallApps([]model.InstalledApp{v}, true)
+func RefreshApp(v model.InstalledApp, trigger model.RefreshTrigger) {
+	instance.StartInstallApps([]model.InstalledApp{v}, true, trigger)
+}
+
+// ReinstallApp queues a reinstall of the saved IPA of v, see Task.ReinstallApp.
+func ReinstallApp(v model.InstalledApp, trigger model.RefreshTrigger) {
+	instance.ReinstallApp(v, trigger)
 }
 
-func StartInstallApps(apps []model.InstalledApp, notify bool) {
-	instance.StartInstallApps(apps, notify)
+func StartInstallApps(apps []model.InstalledApp, notify bool, trigger model.RefreshTrigger) {
+	instance.StartInstallApps(apps, notify, trigger)
 }
 
 func GetCurrentInstallingApps() []model.InstalledApp {
@@ -476,6 +652,7 @@ func ReloadTask() error {
 	return instance.RunSchedule()
 }
 
+// RefreshDeviceApps refreshes the apps of the device on request of the user.
 func RefreshDeviceApps(device model.Device) error {
-	return instance.refreshDeviceApps(device)
+	return instance.refreshDeviceApps(device, model.RefreshTriggerManual)
 }
@@ -0,0 +1,120 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/notify"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+var (
+	// ErrCodeExpired ends a job nobody entered the 2FA code for in time.
+	ErrCodeExpired  = errors.New("2FA code was not entered in time")
+	ErrNoCodePrompt = errors.New("job is not waiting for a 2FA code")
+	ErrInvalidCode  = errors.New("the 2FA code must be 6 digits")
+)
+
+// codeTimeout is how long an unattended install waits for a 2FA code.
+var codeTimeout = 15 * time.Minute
+
+var codeRegex = regexp.MustCompile(`^\d{6}$`)
+
+// codePrompt is a job waiting for a 2FA code.
+type codePrompt struct {
+	installMgr *manager.InstallManager
+	timer      *time.Timer
+}
+
+// awaitCode pauses the job of item in the awaiting_2fa state and notifies the
+// user. The job is stopped when no code is entered within codeTimeout.
+func (t *Task) awaitCode(item TaskItem, installMgr *manager.InstallManager) {
+	expiresAt := time.Now().Add(codeTimeout)
+	prompt := &codePrompt{installMgr: installMgr}
+	prompt.timer = time.AfterFunc(codeTimeout, func() { t.expireCode(item) })
+	if old, loaded := t.codePrompts.Swap(item.JobID, prompt); loaded {
+		old.(*codePrompt).timer.Stop()
+	}
+
+	if err := service.AwaitJobCode(item.JobID, expiresAt); err != nil {
+		log.Err(err).Msgf("Failed to save install job state: %s", item.App.IpaName)
+	}
+	log.Warnf("Apple asked for a 2FA code, waiting until %s: %s", expiresAt.Format(time.DateTime), item.App.IpaName)
+	go t.sendCodeNotification(item, expiresAt)
+}
+
+// expireCode stops the job of item when its 2FA code is still missing.
+func (t *Task) expireCode(item TaskItem) {
+	if _, ok := t.codePrompts.LoadAndDelete(item.JobID); !ok {
+		return
+	}
+	log.Warnf("No 2FA code entered in %d minutes, stop installing: %s", int(codeTimeout.Minutes()), item.App.IpaName)
+	if cancel, ok := t.runningJobs.Load(item.JobID); ok {
+		cancel.(context.CancelCauseFunc)(ErrCodeExpired)
+	}
+}
+
+// clearCode drops the 2FA prompt of a finished job.
+func (t *Task) clearCode(jobID uint) {
+	if v, ok := t.codePrompts.LoadAndDelete(jobID); ok {
+		v.(*codePrompt).timer.Stop()
+	}
+}
+
+// SubmitCode forwards a 2FA code to the install of a job waiting for it.
+func (t *Task) SubmitCode(jobID uint, code string) error {
+	code = strings.TrimSpace(code)
+	if !codeRegex.MatchString(code) {
+		return ErrInvalidCode
+	}
+	v, ok := t.codePrompts.LoadAndDelete(jobID)
+	if !ok {
+		return ErrNoCodePrompt
+	}
+	prompt := v.(*codePrompt)
+	prompt.timer.Stop()
+
+	if err := service.ResumeJob(jobID); err != nil {
+		log.Err(err).Msgf("Failed to save install job state: %d", jobID)
+	}
+	log.Infof("2FA code entered, continue install job %d", jobID)
+	prompt.installMgr.Write([]byte(code + "\n"))
+	return nil
+}
+
+func (t *Task) sendCodeNotification(item TaskItem, expiresAt time.Time) {
+	if !app.Settings.Notification.Enabled {
+		return
+	}
+	title := i18n.LocalizeF("notify.code_title", map[string]any{"name": item.App.IpaName})
+	message := i18n.LocalizeF("notify.code_content", map[string]any{
+		"account":    item.App.MaskAccount(),
+		"expiration": expiresAt.Format("15:04"),
+		"link":       codeLink(item.JobID),
+	})
+	if err := notify.Send(title, message); err != nil {
+		log.Err(err).Msgf("Failed to send 2FA code notification: %s", item.App.IpaName)
+	}
+}
+
+// codeLink returns the page to enter the 2FA code of a job, or the API to post
+// it to when the URL of the web UI is not set.
+func codeLink(jobID uint) string {
+	baseURL := strings.TrimRight(app.Settings.Notification.BaseURL, "/")
+	if baseURL == "" {
+		return fmt.Sprintf("POST /api/jobs/%d/2fa", jobID)
+	}
+	return fmt.Sprintf("%s/#/home?code_job=%d", baseURL, jobID)
+}
+
+func SubmitCode(jobID uint, code string) error {
+	return instance.SubmitCode(jobID, code)
+}
@@ -0,0 +1,123 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/notify"
+	"github.com/bitxeno/atvloadly/internal/service"
+)
+
+// The expiry watchdog runs apart from the refresh schedule, so apps that are
+// never refreshed (device offline, invalid account, task disabled) are still
+// noticed before they stop launching.
+const watchdogInterval = 10 * time.Minute
+
+func (t *Task) runWatchdog() {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		t.checkExpiry(time.Now())
+		select {
+		case <-ticker.C:
+		case <-t.chExitWatchdog:
+			return
+		}
+	}
+}
+
+func (t *Task) checkExpiry(now time.Time) {
+	if !app.Settings.Notification.Enabled {
+		return
+	}
+	thresholds, err := ParseExpiryThresholds(app.Settings.Notification.ExpiryAlertHours)
+	if err != nil || len(thresholds) == 0 {
+		return
+	}
+
+	apps, err := service.GetEnableAppList()
+	if err != nil {
+		log.Err(err).Msg("Failed to load apps for the expiry check")
+		return
+	}
+	for _, v := range apps {
+		if v.ExpirationDate == nil {
+			continue
+		}
+		threshold, ok := expiryThreshold(v.ExpirationDate.Sub(now), thresholds)
+		if !ok {
+			continue
+		}
+		sent, err := service.HasExpiryAlert(v.ID, *v.ExpirationDate, threshold)
+		if err != nil || sent {
+			continue
+		}
+
+		t.sendExpiryAlert(v, threshold)
+		if err := service.SaveExpiryAlert(model.ExpiryAlert{AppID: v.ID, ExpirationDate: *v.ExpirationDate, ThresholdHours: threshold}); err != nil {
+			log.Err(err).Msgf("Failed to save expiry alert: %s", v.IpaName)
+		}
+	}
+
+	if err := service.CleanExpiryAlerts(); err != nil {
+		log.Err(err).Msg("Failed to clean expiry alerts")
+	}
+}
+
+func (t *Task) sendExpiryAlert(v model.InstalledApp, threshold int) {
+	title := i18n.LocalizeF("notify.expiry_title", map[string]any{"name": v.IpaName, "hours": threshold})
+	if threshold == 0 {
+		title = i18n.LocalizeF("notify.expired_title", map[string]any{"name": v.IpaName})
+	}
+	message := i18n.LocalizeF("notify.expiry_content", map[string]any{
+		"device":     v.Device,
+		"account":    v.MaskAccount(),
+		"expiration": formatDigestTime(v.ExpirationDate),
+	})
+	log.Warnf("App expiry alert (%dh): %s", threshold, v.IpaName)
+	if err := notify.Send(title, message); err != nil {
+		log.Err(err).Msgf("Failed to send expiry alert: %s", v.IpaName)
+	}
+}
+
+// ParseExpiryThresholds parses a comma separated list of hours before expiry,
+// e.g. "48,24,6". The result is sorted in descending order.
+func ParseExpiryThresholds(v string) ([]int, error) {
+	thresholds := make([]int, 0)
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		hours, err := strconv.Atoi(s)
+		if err != nil || hours <= 0 {
+			return nil, fmt.Errorf("invalid expiry alert hours: %s", s)
+		}
+		thresholds = append(thresholds, hours)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(thresholds)))
+	return thresholds, nil
+}
+
+// expiryThreshold returns the closest threshold the app has passed, or 0 once
+// it has expired. thresholds must be sorted in descending order.
+func expiryThreshold(remaining time.Duration, thresholds []int) (int, bool) {
+	if remaining <= 0 {
+		return 0, true
+	}
+	threshold, ok := 0, false
+	for _, hours := range thresholds {
+		if remaining <= time.Duration(hours)*time.Hour {
+			threshold, ok = hours, true
+		}
+	}
+	return threshold, ok
+}
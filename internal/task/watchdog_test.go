@@ -0,0 +1,130 @@
+package task
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/i18n"
+	"github.com/bitxeno/atvloadly/internal/model"
+)
+
+func TestParseExpiryThresholds(t *testing.T) {
+	got, err := ParseExpiryThresholds(" 6, 48,24 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{48, 24, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if got, err := ParseExpiryThresholds(""); err != nil || len(got) != 0 {
+		t.Fatalf("empty value should disable alerts, got %v %v", got, err)
+	}
+	for _, v := range []string{"24,abc", "0", "-6"} {
+		if _, err := ParseExpiryThresholds(v); err == nil {
+			t.Errorf("%q should be rejected", v)
+		}
+	}
+}
+
+func TestExpiryThreshold(t *testing.T) {
+	thresholds := []int{48, 24, 6}
+	cases := []struct {
+		remaining time.Duration
+		threshold int
+		ok        bool
+	}{
+		{72 * time.Hour, 0, false},
+		{48 * time.Hour, 48, true},
+		{30 * time.Hour, 48, true},
+		{23 * time.Hour, 24, true},
+		{time.Hour, 6, true},
+		{0, 0, true},
+		{-time.Hour, 0, true},
+	}
+	for _, c := range cases {
+		threshold, ok := expiryThreshold(c.remaining, thresholds)
+		if threshold != c.threshold || ok != c.ok {
+			t.Errorf("expiryThreshold(%s) = %d, %v; want %d, %v", c.remaining, threshold, ok, c.threshold, c.ok)
+		}
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	dir := t.TempDir()
+	app.Config = &app.Configuration{}
+	app.Config.Server.DataDir = dir
+	app.Settings = &app.SettingsConfiguration{}
+	app.Settings.Notification.Enabled = true
+	app.Settings.Notification.ExpiryAlertHours = "48,24,6"
+	i18n.Init(os.DirFS("../.."))
+
+	if err := db.Open(db.Config{Path: dir, FileName: "test.db"}).AutoMigrate(
+		&model.InstalledApp{},
+		&model.ExpiryAlert{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	now := time.Now()
+	expiration := now.Add(30 * time.Hour)
+	v := model.InstalledApp{IpaName: "Fake", Enabled: true, ExpirationDate: &expiration}
+	if err := db.Store().Create(&v).Error; err != nil {
+		t.Fatal(err)
+	}
+	alerts := func() []int {
+		t.Helper()
+		var thresholds []int
+		if err := db.Store().Model(&model.ExpiryAlert{}).Order("id asc").Pluck("threshold_hours", &thresholds).Error; err != nil {
+			t.Fatal(err)
+		}
+		return thresholds
+	}
+
+	task := new()
+	steps := []struct {
+		at   time.Duration
+		want []int
+	}{
+		{0, []int{48}},
+		{time.Hour, []int{48}},
+		{7 * time.Hour, []int{48, 24}},
+		{8 * time.Hour, []int{48, 24}},
+		{25 * time.Hour, []int{48, 24, 6}},
+		{31 * time.Hour, []int{48, 24, 6, 0}},
+	}
+	for _, step := range steps {
+		task.checkExpiry(now.Add(step.at))
+		if got := alerts(); !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("alerts after %s = %v, want %v", step.at, got, step.want)
+		}
+	}
+
+	// an app left expired is not alerted again, however old the alerts are
+	if err := db.Store().Model(&model.ExpiryAlert{}).Where("1 = 1").Update("created_at", now.AddDate(0, 0, -45)).Error; err != nil {
+		t.Fatal(err)
+	}
+	task.checkExpiry(now.AddDate(0, 0, 45))
+	if got := alerts(); !reflect.DeepEqual(got, []int{48, 24, 6, 0}) {
+		t.Fatalf("alerts of an app left expired = %v, want no new ones", got)
+	}
+
+	// a refresh starts over the thresholds
+	refreshed := now.Add(7 * 24 * time.Hour)
+	if err := db.Store().Model(&v).Update("expiration_date", refreshed).Error; err != nil {
+		t.Fatal(err)
+	}
+	task.checkExpiry(now)
+	if got := alerts(); len(got) != 0 {
+		t.Fatalf("alerts after refresh = %v, want the old ones cleaned", got)
+	}
+	task.checkExpiry(refreshed.Add(-47 * time.Hour))
+	if got := alerts(); !reflect.DeepEqual(got, []int{48}) {
+		t.Fatalf("alerts = %v, want 48 sent again for the new expiration", got)
+	}
+}
@@ -0,0 +1,110 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/log"
+)
+
+// How often Watch renews a self-signed certificate, see RenewSelfSigned.
+const renewInterval = time.Hour
+
+// Reloader serves a certificate pair from disk and picks up changes to the
+// files without restarting the server.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	// renew reissues the certificate files when they are close to expiry
+	renew func() error
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate pair from disk. On error the previously loaded
+// certificate stays in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = r.latestModTime()
+	return nil
+}
+
+// RenewSelfSigned makes Watch reissue the self-signed certificate in dir
+// before it expires, see EnsureSelfSigned. The server keeps running on the
+// renewed certificate without a restart.
+func (r *Reloader) RenewSelfSigned(dir string, hosts []string) {
+	r.renew = func() error {
+		_, _, err := EnsureSelfSigned(dir, hosts)
+		return err
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch polls the certificate files and reloads them when they change, until
+// stop is closed.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastRenew time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if r.renew != nil && time.Since(lastRenew) >= renewInterval {
+				lastRenew = time.Now()
+				if err := r.renew(); err != nil {
+					log.Warnf("Renew self-signed TLS certificate failed: %s", err.Error())
+				}
+			}
+
+			r.mu.RLock()
+			changed := r.latestModTime().After(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.Warnf("Reload TLS certificate failed, keep using the previous one: %s", err.Error())
+				continue
+			}
+			log.Infof("TLS certificate reloaded: %s", r.certFile)
+		}
+	}
+}
+
+func (r *Reloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if stat, err := os.Stat(path); err == nil && stat.ModTime().After(latest) {
+			latest = stat.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,130 @@
+package tlscert
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+
+	certFile, keyFile, err := EnsureSelfSigned(dir, []string{"atv.example.lan"})
+	if err != nil {
+		t.Fatalf("EnsureSelfSigned returned error: %v", err)
+	}
+	if stat, err := os.Stat(keyFile); err != nil || stat.Mode().Perm() != 0o600 {
+		t.Fatalf("server key should be written with 0600, got %v, %v", stat, err)
+	}
+
+	cert, err := readCert(certFile)
+	if err != nil {
+		t.Fatalf("read server certificate: %v", err)
+	}
+	ca, err := readCert(filepath.Join(dir, CAFileName))
+	if err != nil {
+		t.Fatalf("read CA certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, name := range []string{"localhost", "127.0.0.1", "atv.example.lan"} {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Fatalf("certificate should be valid for %s: %v", name, err)
+		}
+	}
+
+	// a second call keeps the existing certificate
+	if _, _, err := EnsureSelfSigned(dir, []string{"atv.example.lan"}); err != nil {
+		t.Fatalf("EnsureSelfSigned returned error: %v", err)
+	}
+	again, _ := readCert(certFile)
+	if again.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Fatalf("certificate should not be regenerated")
+	}
+
+	// a new host name reissues the server certificate with the same CA
+	if _, _, err := EnsureSelfSigned(dir, []string{"other.example.lan"}); err != nil {
+		t.Fatalf("EnsureSelfSigned returned error: %v", err)
+	}
+	reissued, _ := readCert(certFile)
+	if reissued.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+		t.Fatalf("certificate should be reissued for new host names")
+	}
+	if err := reissued.CheckSignatureFrom(ca); err != nil {
+		t.Fatalf("reissued certificate should be signed by the existing CA: %v", err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := EnsureSelfSigned(dir, nil)
+	if err != nil {
+		t.Fatalf("EnsureSelfSigned returned error: %v", err)
+	}
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader returned error: %v", err)
+	}
+	before, _ := r.GetCertificate(nil)
+
+	if err := os.Remove(certFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := EnsureSelfSigned(dir, nil); err != nil {
+		t.Fatalf("EnsureSelfSigned returned error: %v", err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	after, _ := r.GetCertificate(nil)
+	if string(before.Certificate[0]) == string(after.Certificate[0]) {
+		t.Fatalf("Reload should pick up the new certificate")
+	}
+}
+
+func TestReloaderRenewSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := EnsureSelfSigned(dir, nil)
+	if err != nil {
+		t.Fatalf("EnsureSelfSigned returned error: %v", err)
+	}
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader returned error: %v", err)
+	}
+	r.RenewSelfSigned(dir, nil)
+	before, _ := r.GetCertificate(nil)
+
+	// a missing certificate is reissued like an expiring one
+	if err := os.Remove(certFile); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Watch(10*time.Millisecond, stop)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		after, _ := r.GetCertificate(nil)
+		if string(before.Certificate[0]) != string(after.Certificate[0]) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Watch should renew and reload the certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch should return once stop is closed")
+	}
+}
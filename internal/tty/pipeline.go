@@ -14,6 +14,7 @@ import (
 type PipeLine struct {
 	pty console.Console
 	skt *websocket.Conn
+	rec *Recorder
 }
 
 // NewPipeLine Malloc PipeLine
@@ -26,7 +27,7 @@ func NewPipeLine(conn *websocket.Conn, command string) (*PipeLine, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &PipeLine{proc, conn}, nil
+	return &PipeLine{pty: proc, skt: conn}, nil
 }
 
 // ReadSktAndWritePty read skt and write pty
@@ -60,6 +61,7 @@ func (w *PipeLine) ReadSktAndWritePty(logChan chan string) {
 				logChan <- fmt.Sprintf("Error ReadSktAndWritePty pty resize failed: %s", err)
 				return
 			}
+			w.rec.Resize(size[0], size[1])
 		case lib.TypeData:
 			var dat string
 			err := json.Unmarshal(msg.Data, &dat)
@@ -88,6 +90,7 @@ func (w *PipeLine) ReadPtyAndWriteSkt(logChan chan string) {
 			logChan <- fmt.Sprintf("Error ReadPtyAndWriteSkt pty read failed: %s", err)
 			return
 		}
+		w.rec.Output(buf[:n])
 		err = w.skt.WriteMessage(websocket.TextMessage, buf[:n])
 		if err != nil {
 			logChan <- fmt.Sprintf("Error ReadPtyAndWriteSkt skt write failed: %s", err)
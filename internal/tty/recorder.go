@@ -0,0 +1,171 @@
+package tty
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const recordingExt = ".cast"
+
+var regRecordingName = regexp.MustCompile(`^[\w.-]+\.cast$`)
+
+// Recorder writes a terminal session in asciinema v2 cast format.
+// Only output and resize events are recorded, raw keystrokes are not, so
+// input hidden by prompts (e.g. passwords) never reaches the disk.
+type Recorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	start time.Time
+}
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recording describes a saved session.
+type Recording struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewRecorder creates a new cast file in dir.
+func NewRecorder(dir string, title string, cols int, rows int) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := now.Format("20060102-150405.000000") + recordingExt
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	header, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: now.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if _, err := f.Write(append(header, '\n')); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &Recorder{f: f, start: now}, nil
+}
+
+// Output records data written to the terminal.
+func (r *Recorder) Output(data []byte) {
+	r.event("o", string(data))
+}
+
+// Resize records a terminal size change.
+func (r *Recorder) Resize(cols int, rows int) {
+	r.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (r *Recorder) event(kind string, data string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return
+	}
+
+	line, _ := json.Marshal([]any{time.Since(r.start).Seconds(), kind, data})
+	_, _ = r.f.Write(append(line, '\n'))
+}
+
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil {
+		_ = r.f.Close()
+		r.f = nil
+	}
+}
+
+// ListRecordings returns the recordings in dir, newest first.
+func ListRecordings(dir string) ([]Recording, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Recording{}, nil
+		}
+		return nil, err
+	}
+
+	recordings := []Recording{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), recordingExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		rec := Recording{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()}
+		if header, err := readCastHeader(filepath.Join(dir, entry.Name())); err == nil {
+			rec.Title = header.Title
+			rec.CreatedAt = time.Unix(header.Timestamp, 0)
+		}
+		recordings = append(recordings, rec)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].CreatedAt.After(recordings[j].CreatedAt)
+	})
+	return recordings, nil
+}
+
+// RecordingPath returns the file path of a recording, rejecting names that
+// could escape dir.
+func RecordingPath(dir string, name string) (string, error) {
+	if !regRecordingName.MatchString(name) {
+		return "", fmt.Errorf("invalid recording name: %s", name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func readCastHeader(path string) (*castHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var header castHeader
+	if err := json.NewDecoder(f).Decode(&header); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
@@ -0,0 +1,374 @@
+package tty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bitxeno/atvloadly/internal/tty/lib"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/runletapp/go-console"
+)
+
+const (
+	restrictedCommand = "plumesign"
+	restrictedPrompt  = "\x1b[32mplumesign\x1b[0m> "
+)
+
+// DefaultAllowlist only permits read-only diagnostics. An entry is the
+// subcommand followed by the flags it may be given, each flag takes one value.
+// "..." allows any further positional arguments.
+var DefaultAllowlist = []string{
+	"--help",
+	"--version",
+	"help ...",
+	"device-info -u --ip --port",
+	"check afc --udid --ip --port",
+	"account list",
+	"account devices -u",
+	"certificate list -u",
+}
+
+// helpFlags are accepted after every allowlisted subcommand.
+var helpFlags = []string{"-h", "--help"}
+
+var (
+	ErrEmptyCommand      = errors.New("empty command")
+	ErrCommandNotAllowed = errors.New("command not allowed")
+)
+
+// Restricted is a terminal that only runs allowlisted plumesign subcommands.
+// Input is line edited here and the command is executed directly without a
+// shell, so pipes, redirects and substitutions have no effect.
+type Restricted struct {
+	conn      *websocket.Conn
+	allowlist []string
+	cwd       string
+	rec       *Recorder
+
+	cols int
+	rows int
+
+	writeMu sync.Mutex
+	procMu  sync.Mutex
+	proc    console.Console
+}
+
+func NewRestricted(conn *websocket.Conn, allowlist []string) *Restricted {
+	return &Restricted{
+		conn:      conn,
+		allowlist: allowlist,
+		cols:      120,
+		rows:      60,
+	}
+}
+
+func (t *Restricted) SetCWD(cwd string) {
+	t.cwd = cwd
+}
+
+// SetRecorder records the session output, the recorder is closed with the terminal.
+func (t *Restricted) SetRecorder(rec *Recorder) {
+	t.rec = rec
+}
+
+func (t *Restricted) Close() {
+	t.procMu.Lock()
+	if t.proc != nil {
+		_ = t.proc.Kill()
+	}
+	t.procMu.Unlock()
+	t.rec.Close()
+}
+
+// Start serves the session until the websocket is closed.
+func (t *Restricted) Start() {
+	t.write(fmt.Sprintf("Restricted terminal, allowed commands: %s\r\n", strings.Join(t.allowedCommands(), ", ")))
+	t.write(restrictedPrompt)
+
+	var line []rune
+	for {
+		mt, payload, err := t.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if mt != websocket.TextMessage {
+			return
+		}
+		var msg lib.Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case lib.TypeResize:
+			var size []int
+			if err := json.Unmarshal(msg.Data, &size); err != nil || len(size) < 2 {
+				return
+			}
+			t.resize(size[0], size[1])
+		case lib.TypeData:
+			var dat string
+			if err := json.Unmarshal(msg.Data, &dat); err != nil {
+				return
+			}
+			// forward keystrokes to the running command, e.g. for 2FA prompts
+			if t.forward(dat) {
+				continue
+			}
+			line = t.edit(line, dat)
+		default:
+			return
+		}
+	}
+}
+
+// edit applies input to the current line and runs it on enter.
+func (t *Restricted) edit(line []rune, input string) []rune {
+	// ignore escape sequences such as arrow keys
+	if strings.HasPrefix(input, "\x1b") {
+		return line
+	}
+
+	var echo strings.Builder
+	defer func() {
+		if echo.Len() > 0 {
+			t.write(echo.String())
+		}
+	}()
+
+	for _, r := range input {
+		switch r {
+		case '\r', '\n':
+			echo.WriteString("\r\n")
+			t.write(echo.String())
+			echo.Reset()
+			t.execute(string(line))
+			return nil
+		case 0x7f, '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				echo.WriteString("\b \b")
+			}
+		case 0x03: // Ctrl-C
+			echo.WriteString("^C\r\n" + restrictedPrompt)
+			line = nil
+		default:
+			if r >= 0x20 {
+				line = append(line, r)
+				echo.WriteRune(r)
+			}
+		}
+	}
+	return line
+}
+
+func (t *Restricted) execute(line string) {
+	args, err := CheckCommand(line, t.allowlist)
+	if err != nil {
+		if !errors.Is(err, ErrEmptyCommand) {
+			t.write(fmt.Sprintf("%s\r\n", err.Error()))
+		}
+		t.write(restrictedPrompt)
+		return
+	}
+
+	proc, err := console.New(t.cols, t.rows)
+	if err == nil && t.cwd != "" {
+		err = proc.SetCWD(t.cwd)
+	}
+	if err == nil {
+		err = proc.Start(args)
+	}
+	if err != nil {
+		t.write(fmt.Sprintf("ERROR: %s\r\n%s", err.Error(), restrictedPrompt))
+		return
+	}
+
+	t.procMu.Lock()
+	t.proc = proc
+	t.procMu.Unlock()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := proc.Read(buf)
+			if n > 0 {
+				t.write(string(buf[:n]))
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		state, err := proc.Wait()
+		_ = proc.Close()
+		t.procMu.Lock()
+		t.proc = nil
+		t.procMu.Unlock()
+
+		if err == nil && state != nil && state.ExitCode() != 0 {
+			t.write(fmt.Sprintf("exit status %d\r\n", state.ExitCode()))
+		}
+		t.write(restrictedPrompt)
+	}()
+}
+
+func (t *Restricted) forward(input string) bool {
+	t.procMu.Lock()
+	defer t.procMu.Unlock()
+	if t.proc == nil {
+		return false
+	}
+	_, _ = t.proc.Write([]byte(input))
+	return true
+}
+
+func (t *Restricted) resize(cols int, rows int) {
+	t.procMu.Lock()
+	defer t.procMu.Unlock()
+	t.cols, t.rows = cols, rows
+	if t.proc != nil {
+		_ = t.proc.SetSize(cols, rows)
+	}
+	t.rec.Resize(cols, rows)
+}
+
+func (t *Restricted) write(data string) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	t.rec.Output([]byte(data))
+	_ = t.conn.WriteMessage(websocket.TextMessage, []byte(data))
+}
+
+func (t *Restricted) allowedCommands() []string {
+	commands := make([]string, 0, len(t.allowlist))
+	for _, entry := range t.allowlist {
+		commands = append(commands, restrictedCommand+" "+entry)
+	}
+	return commands
+}
+
+// CheckCommand parses a command line and verifies it is a plumesign command
+// matching an allowlist entry such as "account devices -u": the subcommand
+// words, then only the listed flags with one value each.
+func CheckCommand(line string, allowlist []string) ([]string, error) {
+	args, err := SplitArgs(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, ErrEmptyCommand
+	}
+	if args[0] != restrictedCommand {
+		return nil, fmt.Errorf("%w: only %s is available", ErrCommandNotAllowed, restrictedCommand)
+	}
+
+	for _, entry := range allowlist {
+		if matchEntry(args[1:], entry) {
+			return args, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrCommandNotAllowed, strings.Join(args, " "))
+}
+
+// matchEntry reports whether args are the subcommand of entry followed by its
+// allowed flags.
+func matchEntry(args []string, entry string) bool {
+	var subcommand, flags []string
+	positional := false
+	for _, field := range strings.Fields(entry) {
+		switch {
+		case field == "...":
+			positional = true
+		case strings.HasPrefix(field, "-") && len(subcommand) > 0:
+			flags = append(flags, field)
+		default:
+			subcommand = append(subcommand, field)
+		}
+	}
+	if len(subcommand) == 0 || len(subcommand) > len(args) {
+		return false
+	}
+	for i, word := range subcommand {
+		if args[i] != word {
+			return false
+		}
+	}
+
+	rest := args[len(subcommand):]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if !strings.HasPrefix(arg, "-") {
+			if !positional {
+				return false
+			}
+			continue
+		}
+		if slices.Contains(helpFlags, arg) {
+			continue
+		}
+		name, _, inline := strings.Cut(arg, "=")
+		if !slices.Contains(flags, name) {
+			return false
+		}
+		if !inline {
+			// the flag value is the next argument
+			if i+1 >= len(rest) {
+				return false
+			}
+			i++
+		}
+	}
+	return true
+}
+
+// SplitArgs splits a command line into arguments, honouring single quotes,
+// double quotes and backslash escapes.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
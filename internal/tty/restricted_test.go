@@ -0,0 +1,59 @@
+package tty
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	args, err := SplitArgs(`plumesign account login -u "a b@example.com" -p 'x"y' c\ d`)
+	if err != nil {
+		t.Fatalf("SplitArgs returned error: %v", err)
+	}
+	want := []string{"plumesign", "account", "login", "-u", "a b@example.com", "-p", `x"y`, "c d"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("SplitArgs = %q, want %q", args, want)
+	}
+
+	if _, err := SplitArgs(`plumesign "unterminated`); err == nil {
+		t.Fatalf("SplitArgs should reject unterminated quotes")
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	allowlist := []string{"account list", "device-info -u", "help ..."}
+
+	cases := []struct {
+		line    string
+		allowed bool
+	}{
+		{"plumesign account list", true},
+		{"plumesign device-info -u 0000", true},
+		{"plumesign account", false},
+		{"plumesign account logout -u a@b.c", false},
+		{"bash", false},
+		{"plumesign account list; rm -rf /", false},
+		{"plumesign device-info -u $(id)", true}, // arguments are passed literally, no shell
+		{"plumesign device-info -u=0000", true},
+		{"plumesign device-info --help", true},
+		{"plumesign help account", true},
+		{"plumesign account list -u victim@example.com", false},
+		{"plumesign device-info 0000", false},
+		{"plumesign device-info -u", false},
+		{"plumesign device-info -u 0000 --output /etc/passwd", false},
+	}
+	for _, c := range cases {
+		_, err := CheckCommand(c.line, allowlist)
+		if c.allowed && err != nil {
+			t.Errorf("%q should be allowed: %v", c.line, err)
+		}
+		if !c.allowed && !errors.Is(err, ErrCommandNotAllowed) {
+			t.Errorf("%q should be rejected, got %v", c.line, err)
+		}
+	}
+
+	if _, err := CheckCommand("   ", allowlist); !errors.Is(err, ErrEmptyCommand) {
+		t.Fatalf("empty line should return ErrEmptyCommand, got %v", err)
+	}
+}
@@ -37,9 +37,15 @@ func (t *TTY) SetENV(environ []string) {
 	t.environ = utils.MergeEnvs(os.Environ(), environ)
 }
 
+// SetRecorder records the session output, the recorder is closed with the TTY.
+func (t *TTY) SetRecorder(rec *Recorder) {
+	t.pl.rec = rec
+}
+
 func (t *TTY) Close() {
 	fmt.Println("tty close")
 	t.pl.Close()
+	t.pl.rec.Close()
 }
 
 func (t *TTY) Start() {
@@ -23,3 +23,20 @@ func TestMaskEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if !CheckPassword(hash, "s3cret") {
+		t.Fatal("CheckPassword() should accept the original password")
+	}
+	if CheckPassword(hash, "wrong") {
+		t.Fatal("CheckPassword() should reject a different password")
+	}
+	if CheckPassword("plaintext", "plaintext") {
+		t.Fatal("CheckPassword() should reject malformed hashes")
+	}
+}
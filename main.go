@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/bitxeno/atvloadly/cmd/rotatekey"
 	"github.com/bitxeno/atvloadly/cmd/server"
 	"github.com/bitxeno/atvloadly/internal/app/build"
 	"github.com/bitxeno/atvloadly/internal/i18n"
@@ -31,6 +32,7 @@ func main() {
 		Version: build.Version,
 		Commands: []*cli.Command{
 			server.Command,
+			rotatekey.Command,
 		},
 	}
 
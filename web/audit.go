@@ -0,0 +1,97 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// audit records the caller, action, target and outcome of a state-changing
+// route. The outcome is taken from the ApiResult code of the response.
+func audit(action string, target func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		entry := model.AuditLog{
+			Actor:    auditActor(c),
+			SourceIP: c.IP(),
+			Action:   action,
+			Outcome:  model.AuditOutcomeSuccess,
+		}
+		if target != nil {
+			entry.Target = target(c)
+		}
+		if ok, detail := responseOutcome(c, err); !ok {
+			entry.Outcome = model.AuditOutcomeFailure
+			entry.Detail = detail
+		}
+		service.RecordAudit(entry)
+
+		return err
+	}
+}
+
+func auditActor(c *fiber.Ctx) string {
+	user := currentUser(c)
+	if user == nil {
+		return "anonymous"
+	}
+	if token := currentToken(c); token != nil {
+		return fmt.Sprintf("%s (token: %s)", user.Username, token.Name)
+	}
+	return user.Username
+}
+
+func responseOutcome(c *fiber.Ctx, err error) (bool, string) {
+	if err != nil {
+		return false, err.Error()
+	}
+	if c.Response().StatusCode() >= http.StatusBadRequest {
+		return false, http.StatusText(c.Response().StatusCode())
+	}
+	if !strings.HasPrefix(string(c.Response().Header.ContentType()), fiber.MIMEApplicationJSON) {
+		return true, ""
+	}
+
+	var result ApiResult
+	if err := json.Unmarshal(c.Response().Body(), &result); err != nil {
+		return true, ""
+	}
+	if result.Code != 200 {
+		return false, result.Msg
+	}
+	return true, ""
+}
+
+// auditFields builds an audit target from route params, form values or JSON
+// body fields, e.g. "email=a@b.c serialNumber=123".
+func auditFields(keys ...string) func(c *fiber.Ctx) string {
+	return func(c *fiber.Ctx) string {
+		var body map[string]any
+		if strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEApplicationJSON) {
+			_ = json.Unmarshal(c.Body(), &body)
+		}
+
+		parts := []string{}
+		for _, key := range keys {
+			value := c.Params(key)
+			if value == "" {
+				value = c.FormValue(key)
+			}
+			if value == "" && body != nil {
+				if v, ok := body[key]; ok && v != nil {
+					value = fmt.Sprint(v)
+				}
+			}
+			if value != "" {
+				parts = append(parts, key+"="+value)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+}
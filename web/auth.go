@@ -0,0 +1,129 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	sessionCookieName = "atvloadly_session"
+	localsUserKey     = "user"
+	localsTokenKey    = "token"
+)
+
+func authEnabled() bool {
+	return app.Config.Server.Auth.Enabled
+}
+
+// authenticate resolves the session cookie or bearer token and stores the
+// caller in the request locals. It never rejects a request; use permit for that.
+func authenticate(c *fiber.Ctx) error {
+	if !authEnabled() {
+		return c.Next()
+	}
+
+	if token := c.Cookies(sessionCookieName); token != "" {
+		if user, err := service.GetSessionUser(token); err == nil {
+			c.Locals(localsUserKey, user)
+			return c.Next()
+		}
+	}
+
+	if raw, ok := bearerToken(c); ok {
+		token, user, err := service.AuthenticateToken(raw)
+		if err != nil {
+			return c.Status(http.StatusUnauthorized).JSON(apiError(err.Error()))
+		}
+		c.Locals(localsUserKey, user)
+		c.Locals(localsTokenKey, token)
+	}
+	return c.Next()
+}
+
+// permit rejects callers that may not use scope. Session users are checked
+// against the role the scope requires; token callers additionally need the
+// scope granted on the token. An empty scope only requires a logged in caller.
+// All requests are allowed when authentication is disabled.
+func permit(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !authEnabled() {
+			return c.Next()
+		}
+
+		user := currentUser(c)
+		if user == nil {
+			return c.Status(http.StatusUnauthorized).JSON(apiError("unauthorized"))
+		}
+		if scope == "" {
+			return c.Next()
+		}
+		if !user.Role.Allows(model.ScopeRole(scope)) {
+			return c.Status(http.StatusForbidden).JSON(apiError("permission denied"))
+		}
+		if token := currentToken(c); token != nil && !token.Allows(scope) {
+			return c.Status(http.StatusForbidden).JSON(apiError("token is missing scope: " + scope))
+		}
+		return c.Next()
+	}
+}
+
+// tokenOwner returns the user whose API tokens the caller may list and
+// revoke, or 0 for every user. Only admins logged in with a session manage
+// the tokens of others, a token caller is limited to its own user.
+func tokenOwner(c *fiber.Ctx) uint {
+	user := currentUser(c)
+	if user == nil {
+		return 0
+	}
+	if user.Role == model.UserRoleAdmin && currentToken(c) == nil {
+		return 0
+	}
+	return user.ID
+}
+
+func currentUser(c *fiber.Ctx) *model.User {
+	user, _ := c.Locals(localsUserKey).(*model.User)
+	return user
+}
+
+func currentToken(c *fiber.Ctx) *model.APIToken {
+	token, _ := c.Locals(localsTokenKey).(*model.APIToken)
+	return token
+}
+
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	fields := strings.Fields(c.Get(fiber.HeaderAuthorization))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "bearer") {
+		return "", false
+	}
+	return fields[1], true
+}
+
+func setSessionCookie(c *fiber.Ctx, token string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
@@ -20,7 +20,6 @@ import (
 	"github.com/bitxeno/atvloadly/internal/notify"
 	"github.com/bitxeno/atvloadly/internal/service"
 	"github.com/bitxeno/atvloadly/internal/task"
-	"github.com/bitxeno/atvloadly/internal/tty"
 	"github.com/bitxeno/atvloadly/internal/utils"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
@@ -29,6 +28,9 @@ import (
 )
 
 func route(fi *fiber.App) {
+	fi.Use(authenticate)
+
+	// MCP verifies bearer tokens itself, see mcpserver.NewHTTPHandler
 	mcpHandler := adaptor.HTTPHandler(mcpserver.NewHTTPHandler())
 	fi.All("/mcp", mcpHandler)
 	fi.All("/mcp/*", mcpHandler)
@@ -66,23 +68,12 @@ func route(fi *fiber.App) {
 		}
 		return fiber.ErrUpgradeRequired
 	})
-	fi.Get("/ws/tty", websocket.New(func(c *websocket.Conn) {
-		term, err := tty.New(c, "bash")
-		if err != nil {
-			msg := fmt.Sprintf("ERROR: %s", err.Error())
-			_ = c.WriteMessage(websocket.TextMessage, []byte(msg))
-			return
-		}
-		defer term.Close()
-
-		term.SetCWD(app.Config.Server.DataDir)
-		term.Start()
-	}))
-	fi.Get("/ws/pair", websocket.New(service.HandlePairMessage))
-	fi.Get("/ws/install", websocket.New(service.HandleInstallMessage))
-	fi.Get("/ws/login", websocket.New(service.HandleLoginMessage))
-	fi.Get("/ws/tools/scan", websocket.New(service.HandleScanMessage))
-	fi.Get("/apps/:id/icon", func(c *fiber.Ctx) error {
+	fi.Get("/ws/tty", audit("terminal.open", nil), permit(model.ScopeTerminal), terminalAllowed, websocket.New(trackWebsocket(handleTerminal)))
+	fi.Get("/ws/pair", permit(model.ScopeDevicesWrite), websocket.New(trackWebsocket(service.HandlePairMessage)))
+	fi.Get("/ws/install", audit("app.install.interactive", nil), permit(model.ScopeAppsWrite), websocket.New(trackWebsocket(service.HandleInstallMessage)))
+	fi.Get("/ws/login", audit("account.login", nil), permit(model.ScopeAccountsWrite), websocket.New(trackWebsocket(service.HandleLoginMessage)))
+	fi.Get("/ws/tools/scan", permit(model.ScopeDevicesRead), websocket.New(trackWebsocket(service.HandleScanMessage)))
+	fi.Get("/apps/:id/icon", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
 		id := utils.MustParseInt(c.Params("id"))
 
 		t, err := service.GetApp(uint(id))
@@ -96,7 +87,7 @@ func route(fi *fiber.App) {
 			return c.Status(http.StatusNotFound).SendString("")
 		}
 	})
-	fi.Get("/apps/:id/log", func(c *fiber.Ctx) error {
+	fi.Get("/apps/:id/log", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
 		id := utils.MustParseInt(c.Params("id"))
 
 		path := filepath.Join(app.Config.Server.DataDir, "log", fmt.Sprintf("task_%d.log", id))
@@ -111,7 +102,7 @@ func route(fi *fiber.App) {
 	api.Get("/hello", func(c *fiber.Ctx) error {
 		return c.SendString("hello world.")
 	})
-	api.Post("/lang/sync", func(c *fiber.Ctx) error {
+	api.Post("/lang/sync", permit(""), func(c *fiber.Ctx) error {
 		lang := c.Query("lang")
 		accept := c.Get("Accept-Language")
 		if lang != "" {
@@ -124,11 +115,199 @@ func route(fi *fiber.App) {
 	api.Get("/version", func(c *fiber.Ctx) error {
 		return c.Status(http.StatusOK).JSON(apiSuccess(app.Version))
 	})
-	api.Get("/settings", func(c *fiber.Ctx) error {
+
+	api.Post("/auth/login", audit("auth.login", auditFields("username")), func(c *fiber.Ctx) error {
+		if !authEnabled() {
+			return c.Status(http.StatusOK).JSON(apiError("authentication is disabled"))
+		}
+
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError("Invalid argument"))
+		}
+
+		user, token, err := service.Login(req.Username, req.Password, c.IP())
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+
+		setSessionCookie(c, token, time.Now().Add(service.SessionTTL()))
+		return c.Status(http.StatusOK).JSON(apiSuccess(user))
+	})
+
+	api.Post("/auth/logout", func(c *fiber.Ctx) error {
+		_ = service.Logout(c.Cookies(sessionCookieName))
+		clearSessionCookie(c)
+		return c.Status(http.StatusOK).JSON(apiSuccess(true))
+	})
+
+	api.Get("/auth/me", func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(apiSuccess(map[string]interface{}{
+			"auth_enabled": authEnabled(),
+			"user":         currentUser(c),
+		}))
+	})
+
+	api.Get("/users", permit(model.ScopeUsers), func(c *fiber.Ctx) error {
+		users, err := service.GetUserList()
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(users))
+	})
+
+	api.Post("/users", audit("user.create", auditFields("username", "role")), permit(model.ScopeUsers), func(c *fiber.Ctx) error {
+		var req struct {
+			Username string         `json:"username"`
+			Password string         `json:"password"`
+			Role     model.UserRole `json:"role"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError("Invalid argument"))
+		}
+
+		user, err := service.CreateUser(req.Username, req.Password, req.Role)
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(user))
+	})
+
+	api.Post("/users/:id", audit("user.update", auditFields("id", "role")), permit(model.ScopeUsers), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		var req struct {
+			Password string         `json:"password"`
+			Role     model.UserRole `json:"role"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError("Invalid argument"))
+		}
+
+		user, err := service.UpdateUser(uint(id), req.Password, req.Role)
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(user))
+	})
+
+	api.Post("/users/:id/delete", audit("user.delete", auditFields("id")), permit(model.ScopeUsers), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		if err := service.DeleteUser(uint(id)); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(true))
+	})
+
+	api.Get("/terminal/recordings", permit(model.ScopeTerminal), handleTerminalRecordingList)
+	api.Get("/terminal/recordings/:name", permit(model.ScopeTerminal), handleTerminalRecording)
+
+	api.Get("/audit", permit(model.ScopeAuditRead), func(c *fiber.Ctx) error {
+		filter := service.AuditFilter{
+			Actor:   c.Query("actor"),
+			Action:  c.Query("action"),
+			Target:  c.Query("target"),
+			Outcome: model.AuditOutcome(c.Query("outcome")),
+			Limit:   c.QueryInt("limit"),
+			Offset:  c.QueryInt("offset"),
+		}
+		if since := c.Query("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				return c.Status(http.StatusOK).JSON(apiError("invalid since, expected RFC3339 time"))
+			}
+			filter.Since = &t
+		}
+		if until := c.Query("until"); until != "" {
+			t, err := time.Parse(time.RFC3339, until)
+			if err != nil {
+				return c.Status(http.StatusOK).JSON(apiError("invalid until, expected RFC3339 time"))
+			}
+			filter.Until = &t
+		}
+
+		logs, total, err := service.QueryAuditLogs(filter)
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(map[string]interface{}{
+			"total": total,
+			"items": logs,
+		}))
+	})
+
+	api.Get("/tokens", permit(model.ScopeTokensRead), func(c *fiber.Ctx) error {
+		tokens, err := service.GetAPITokenList(tokenOwner(c))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(tokens))
+	})
+
+	api.Post("/tokens", audit("token.create", auditFields("name")), permit(model.ScopeTokensWrite), func(c *fiber.Ctx) error {
+		if !authEnabled() {
+			return c.Status(http.StatusOK).JSON(apiError("authentication is disabled"))
+		}
+
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError("Invalid argument"))
+		}
+
+		// a token can only issue tokens with a subset of its own scopes
+		if parent := currentToken(c); parent != nil {
+			for _, scope := range req.Scopes {
+				if !parent.Allows(scope) {
+					return c.Status(http.StatusOK).JSON(apiError("token is missing scope: " + scope))
+				}
+			}
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresInDays > 0 {
+			t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			expiresAt = &t
+		}
+
+		raw, token, err := service.CreateAPIToken(*currentUser(c), req.Name, req.Scopes, expiresAt)
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(map[string]interface{}{
+			"token": raw,
+			"info":  token,
+		}))
+	})
+
+	api.Post("/tokens/:id/revoke", audit("token.revoke", auditFields("id")), permit(model.ScopeTokensWrite), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		token, err := service.GetAPIToken(uint(id))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		if owner := tokenOwner(c); owner != 0 && token.UserID != owner {
+			return c.Status(http.StatusForbidden).JSON(apiError("permission denied"))
+		}
+
+		if err := service.RevokeAPIToken(token.ID); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(true))
+	})
+	api.Get("/settings", permit(model.ScopeSettingsRead), func(c *fiber.Ctx) error {
 		return c.Status(http.StatusOK).JSON(apiSuccess(app.Settings))
 	})
 
-	api.Get("/accounts", func(c *fiber.Ctx) error {
+	api.Get("/accounts", permit(model.ScopeAccountsRead), func(c *fiber.Ctx) error {
 		accounts, err := manager.GetAppleAccounts()
 		if err != nil {
 			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
@@ -137,7 +316,21 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(accounts.Accounts))
 	})
 
-	api.Post("/accounts/logout", func(c *fiber.Ctx) error {
+	api.Get("/quota", permit(model.ScopeAccountsRead), func(c *fiber.Ctx) error {
+		emails := []string{}
+		if accounts, err := manager.GetAppleAccounts(); err == nil {
+			for _, v := range accounts.Accounts {
+				emails = append(emails, v.Email)
+			}
+		}
+		quotas, err := service.GetQuotaList(emails, time.Now())
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(quotas))
+	})
+
+	api.Post("/accounts/logout", audit("account.logout", auditFields("email")), permit(model.ScopeAccountsWrite), func(c *fiber.Ctx) error {
 		var req struct {
 			Email string `json:"email"`
 		}
@@ -151,7 +344,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Get("/accounts/devices", func(c *fiber.Ctx) error {
+	api.Get("/accounts/devices", permit(model.ScopeAccountsRead), func(c *fiber.Ctx) error {
 		email := c.Query("email")
 		if email == "" {
 			return c.Status(http.StatusOK).JSON(apiError("email is required"))
@@ -163,7 +356,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(devices))
 	})
 
-	api.Post("/accounts/devices/delete", func(c *fiber.Ctx) error {
+	api.Post("/accounts/devices/delete", audit("account.device.delete", auditFields("email", "deviceId")), permit(model.ScopeAccountsWrite), func(c *fiber.Ctx) error {
 		var req struct {
 			Email    string `json:"email"`
 			DeviceID string `json:"deviceId"`
@@ -177,7 +370,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Get("/certificates", func(c *fiber.Ctx) error {
+	api.Get("/certificates", permit(model.ScopeAccountsRead), func(c *fiber.Ctx) error {
 		email := c.Query("email")
 		if email == "" {
 			return c.Status(http.StatusOK).JSON(apiError("email is required"))
@@ -189,7 +382,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(certs))
 	})
 
-	api.Post("/certificates/revoke", func(c *fiber.Ctx) error {
+	api.Post("/certificates/revoke", audit("certificate.revoke", auditFields("email", "serialNumber")), permit(model.ScopeAccountsWrite), func(c *fiber.Ctx) error {
 		var req struct {
 			Email        string `json:"email"`
 			SerialNumber string `json:"serialNumber"`
@@ -203,7 +396,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Post("/certificates/export", func(c *fiber.Ctx) error {
+	api.Post("/certificates/export", audit("certificate.export", auditFields("email")), permit(model.ScopeAccountsWrite), func(c *fiber.Ctx) error {
 		var req struct {
 			Email    string `json:"email"`
 			Password string `json:"password"`
@@ -223,7 +416,7 @@ func route(fi *fiber.App) {
 		return c.Send(content)
 	})
 
-	api.Post("/certificates/import", func(c *fiber.Ctx) error {
+	api.Post("/certificates/import", audit("certificate.import", auditFields("email")), permit(model.ScopeAccountsWrite), func(c *fiber.Ctx) error {
 		email := c.FormValue("email")
 		password := c.FormValue("password")
 		file, err := c.FormFile("file")
@@ -251,7 +444,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Post("/settings/:key", func(c *fiber.Ctx) error {
+	api.Post("/settings/:key", audit("settings.update", auditFields("key")), permit(model.ScopeSettingsWrite), func(c *fiber.Ctx) error {
 		var settings app.SettingsConfiguration
 		if err := c.BodyParser(&settings); err != nil {
 			return c.Status(http.StatusOK).JSON(apiError("Invalid argument. error: " + err.Error()))
@@ -260,10 +453,27 @@ func route(fi *fiber.App) {
 		key := c.Params("key")
 		switch key {
 		case "notification":
+			switch settings.Notification.Mode {
+			case app.NotifyModeFailures, app.NotifyModeBatch, app.NotifyModeDigest:
+			default:
+				return c.Status(http.StatusOK).JSON(apiError("Invalid notification mode"))
+			}
+			if err := task.ValidateDigestTime(settings.Notification.DigestTime); err != nil {
+				return c.Status(http.StatusOK).JSON(apiError(fmt.Sprintf("invalid digest time: %s", err.Error())))
+			}
+			if _, err := task.ParseExpiryThresholds(settings.Notification.ExpiryAlertHours); err != nil {
+				return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+			}
 			app.Settings.Notification = settings.Notification
+			if err := task.ReloadTask(); err != nil {
+				return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+			}
 		case "network":
 			app.Settings.Network = settings.Network
 		case "task":
+			if settings.Task.IpaVersions < 1 {
+				return c.Status(http.StatusOK).JSON(apiError("ipa_versions must be at least 1"))
+			}
 			app.Settings.Task = settings.Task
 			if err := task.ReloadTask(); err != nil {
 				errMsg := fmt.Sprintf("invalid time format: %s", err.Error())
@@ -275,7 +485,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Get("/devices", func(c *fiber.Ctx) error {
+	api.Get("/devices", permit(model.ScopeDevicesRead), func(c *fiber.Ctx) error {
 		manager.ReloadDevices()
 
 		devices, err := manager.GetDevices()
@@ -286,7 +496,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Get("/devices/:id", func(c *fiber.Ctx) error {
+	api.Get("/devices/:id", permit(model.ScopeDevicesRead), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if device, ok := manager.GetDeviceDetail(id); ok {
 			return c.Status(http.StatusOK).JSON(apiSuccess(device))
@@ -295,7 +505,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiError("device not found"))
 	})
 
-	api.Post("/devices/:id/mountimage", func(c *fiber.Ctx) error {
+	api.Post("/devices/:id/mountimage", audit("device.mountimage", auditFields("id")), permit(model.ScopeDevicesWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 
 		if err := service.MountDeveloperDiskImage(c.Context(), id); err != nil {
@@ -305,7 +515,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Post("/devices/:id/screenshot", func(c *fiber.Ctx) error {
+	api.Post("/devices/:id/screenshot", permit(model.ScopeDevicesWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 
 		data, err := service.TakeDeviceScreenshot(c.Context(), id)
@@ -318,7 +528,7 @@ func route(fi *fiber.App) {
 		}))
 	})
 
-	api.Post("/devices/:id/check/afc", func(c *fiber.Ctx) error {
+	api.Post("/devices/:id/check/afc", permit(model.ScopeDevicesWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 
 		if err := service.CheckAfcService(c.Context(), id); err != nil {
@@ -328,7 +538,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Get("/scan", func(c *fiber.Ctx) error {
+	api.Get("/scan", permit(model.ScopeDevicesRead), func(c *fiber.Ctx) error {
 		manager.ScanDevices()
 
 		devices, err := manager.GetDevices()
@@ -339,7 +549,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Get("/scan/wireless", func(c *fiber.Ctx) error {
+	api.Get("/scan/wireless", permit(model.ScopeDevicesRead), func(c *fiber.Ctx) error {
 		timeout := 3
 		if timeoutStr := c.Query("timeout"); timeoutStr != "" {
 			if t := utils.MustParseInt(timeoutStr); t > 0 {
@@ -356,13 +566,13 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Get("/reload", func(c *fiber.Ctx) error {
+	api.Get("/reload", permit(model.ScopeDevicesRead), func(c *fiber.Ctx) error {
 		manager.ReloadDevices()
 
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Post("/pair", func(c *fiber.Ctx) error {
+	api.Post("/pair", permit(model.ScopeDevicesWrite), func(c *fiber.Ctx) error {
 		devices, err := manager.GetDevices()
 		if err != nil {
 			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
@@ -371,7 +581,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Post("/pair/import", func(c *fiber.Ctx) error {
+	api.Post("/pair/import", audit("pair.import", auditFields("ip", "port")), permit(model.ScopeDevicesWrite), func(c *fiber.Ctx) error {
 		file, err := c.FormFile("file")
 		if err != nil {
 			return c.Status(http.StatusOK).JSON(apiError("No file uploaded"))
@@ -402,7 +612,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess("success"))
 	})
 
-	api.Post("/upload", func(c *fiber.Ctx) error {
+	api.Post("/upload", permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
 		form, _ := c.MultipartForm()
 		files := form.File["files"]
 
@@ -443,15 +653,23 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(result))
 	})
 
-	api.Post("/install", func(c *fiber.Ctx) error {
+	api.Post("/install", audit("app.install", auditFields("account", "url", "device_id", "override_bundle_id")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
 		account := strings.TrimSpace(c.FormValue("account"))
 		ipaURL := strings.TrimSpace(c.FormValue("url"))
 		deviceID := strings.TrimSpace(c.FormValue("device_id"))
 		removeExt := c.FormValue("remove_extensions") == "true"
+		overrides := ipa.Overrides{
+			BundleIdentifier: strings.TrimSpace(c.FormValue("override_bundle_id")),
+			DisplayName:      strings.TrimSpace(c.FormValue("override_name")),
+			Version:          strings.TrimSpace(c.FormValue("override_version")),
+		}
 
 		if account == "" {
 			return c.Status(http.StatusOK).JSON(apiError("account is required"))
 		}
+		if err := overrides.Validate(); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
 
 		var ipaPath string
 		var ipaName string
@@ -525,9 +743,19 @@ func route(fi *fiber.App) {
 			Account:          account,
 			Enabled:          true,
 			RemoveExtensions: removeExt,
+			OverrideBundleID: overrides.BundleIdentifier,
+			OverrideName:     overrides.DisplayName,
+			OverrideVersion:  overrides.Version,
+		}
+		appModel.ApplyOverrides()
+		if err := service.CheckQuota(appModel, time.Now()); err != nil {
+			if file != nil {
+				_ = os.Remove(ipaPath)
+			}
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
 		}
 
-		task.StartInstallApps([]model.InstalledApp{appModel}, true)
+		task.StartInstallApps([]model.InstalledApp{appModel}, true, model.RefreshTriggerManual)
 
 		return c.Status(http.StatusOK).JSON(apiSuccess(map[string]interface{}{
 			"status":  "installing",
@@ -538,20 +766,180 @@ func route(fi *fiber.App) {
 		}))
 	})
 
-	api.Get("/apps", func(c *fiber.Ctx) error {
+	api.Get("/apps", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
 		apps, err := service.GetAppList()
 		if err != nil {
 			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
 		} else {
+			task.SetNextPlannedRuns(apps)
 			return c.Status(http.StatusOK).JSON(apiSuccess(apps))
 		}
 	})
 
-	api.Get("/apps/installing", func(c *fiber.Ctx) error {
+	api.Post("/apps/:id/schedule", audit("app.schedule.update", auditFields("id", "refresh_cron", "advance_days")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		var req struct {
+			RefreshCron string `json:"refresh_cron"`
+			AdvanceDays int    `json:"advance_days"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError("Invalid argument"))
+		}
+		req.RefreshCron = strings.TrimSpace(req.RefreshCron)
+		if req.RefreshCron != "" {
+			if err := task.ValidateSchedule(req.RefreshCron); err != nil {
+				return c.Status(http.StatusOK).JSON(apiError(fmt.Sprintf("invalid time format: %s", err.Error())))
+			}
+		}
+		if req.AdvanceDays < 0 || req.AdvanceDays > 6 {
+			return c.Status(http.StatusOK).JSON(apiError("advance_days must be between 0 and 6"))
+		}
+
+		t, err := service.UpdateAppSchedule(uint(id), req.RefreshCron, req.AdvanceDays)
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		if err := task.ReloadTask(); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+
+		t.NextRefreshAt = task.NextPlannedRun(*t, time.Now())
+		return c.Status(http.StatusOK).JSON(apiSuccess(t))
+	})
+
+	api.Get("/apps/:id/attempts", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		attempts, err := service.GetAppAttempts(uint(id), c.QueryInt("limit"))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(attempts))
+	})
+
+	api.Get("/apps/:id/versions", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		versions, err := service.GetIpaVersions(uint(id))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(versions))
+	})
+
+	api.Post("/apps/:id/versions/:vid/install", audit("app.rollback", auditFields("id", "vid")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+		vid := utils.MustParseInt(c.Params("vid"))
+
+		t, err := service.RestoreIpaVersion(uint(id), uint(vid))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+
+		task.ReinstallApp(*t, model.RefreshTriggerManual)
+		return c.Status(http.StatusOK).JSON(apiSuccess(t))
+	})
+
+	api.Get("/attempts/:id/log", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		attempt, err := service.GetAttempt(uint(id))
+		if err != nil {
+			return c.Status(http.StatusNotFound).SendString(err.Error())
+		}
+		c.Set("Cache-Control", "no-cache, no-store, must-revalidate;")
+		c.Set("pragma", "no-cache")
+		return c.Status(http.StatusOK).SendFile(manager.AttemptLogPath(attempt.ID), false)
+	})
+
+	api.Get("/refresh/plan", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		plan, err := task.PreviewRefreshPlan(task.PlanOptions{
+			Cron:        strings.TrimSpace(c.Query("cron")),
+			AdvanceDays: c.QueryInt("advance_days"),
+			Firings:     c.QueryInt("firings"),
+		})
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(plan))
+	})
+
+	api.Get("/apps/installing", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
 		return c.Status(http.StatusOK).JSON(apiSuccess(task.GetCurrentInstallingApps()))
 	})
 
-	api.Get("/apps/refresh", func(c *fiber.Ctx) error {
+	api.Get("/jobs", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		jobs, err := service.GetJobList(model.JobState(c.Query("state")), c.QueryInt("limit"))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(jobs))
+	})
+
+	api.Get("/jobs/:id", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		job, err := service.GetJob(uint(id))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		attempts, err := service.GetJobAttempts(job.ID)
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(map[string]interface{}{
+			"job":      job,
+			"attempts": attempts,
+		}))
+	})
+
+	api.Get("/queue", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		running, pending, err := task.GetQueue()
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(map[string]interface{}{
+			"running": running,
+			"pending": pending,
+		}))
+	})
+
+	api.Get("/batches", permit(model.ScopeAppsRead), func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(apiSuccess(task.GetActiveBatches()))
+	})
+
+	api.Delete("/jobs/:id", audit("job.cancel", auditFields("id")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		job, err := task.CancelJob(uint(id))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(job))
+	})
+
+	api.Post("/jobs/:id/2fa", audit("job.2fa", auditFields("id")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
+		id := utils.MustParseInt(c.Params("id"))
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError("Invalid argument"))
+		}
+
+		if err := task.SubmitCode(uint(id), req.Code); err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		job, err := service.GetJob(uint(id))
+		if err != nil {
+			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+		}
+		return c.Status(http.StatusOK).JSON(apiSuccess(job))
+	})
+
+	api.Get("/apps/refresh", audit("app.refresh.all", nil), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
 		// wait a moment to ensure device connected
 		time.Sleep(5 * time.Second)
 
@@ -567,7 +955,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Post("/clean", func(c *fiber.Ctx) error {
+	api.Post("/clean", permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
 		var ipa model.IpaFile
 		if err := c.BodyParser(&ipa); err != nil {
 			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
@@ -584,7 +972,7 @@ func route(fi *fiber.App) {
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Post("/apps/:id/delete", func(c *fiber.Ctx) error {
+	api.Post("/apps/:id/delete", audit("app.delete", auditFields("id")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
 		id := utils.MustParseInt(c.Params("id"))
 
 		ok, err := service.DeleteApp(uint(id))
@@ -595,7 +983,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Post("/apps/:id/refresh", func(c *fiber.Ctx) error {
+	api.Post("/apps/:id/refresh", audit("app.refresh", auditFields("id")), permit(model.ScopeAppsWrite), func(c *fiber.Ctx) error {
 		id := utils.MustParseInt(c.Params("id"))
 
 		t, err := service.GetApp(uint(id))
@@ -603,24 +991,24 @@ func route(fi *fiber.App) {
 			return c.Status(http.StatusOK).JSON(apiError(err.Error()))
 		}
 
-		task.RefreshApp(*t)
+		task.RefreshApp(*t, model.RefreshTriggerManual)
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Get("/service/status", func(c *fiber.Ctx) error {
+	api.Get("/service/status", permit(model.ScopeDevicesRead), func(c *fiber.Ctx) error {
 		status := service.GetServiceStatus()
 		return c.Status(http.StatusOK).JSON(apiSuccess(status))
 	})
 
 	// Download latest Apple Music APK and place into PlumeImpactor lib directory (CoreADI update)
-	api.Post("/settings/update/coreadi", func(c *fiber.Ctx) error {
+	api.Post("/settings/update/coreadi", audit("settings.coreadi.update", nil), permit(model.ScopeSettingsWrite), func(c *fiber.Ctx) error {
 		if err := service.UpdateCoreADI(); err != nil {
 			return c.Status(http.StatusOK).JSON(apiError("update failed: " + err.Error()))
 		}
 		return c.Status(http.StatusOK).JSON(apiSuccess(true))
 	})
 
-	api.Get("/notify/send", func(c *fiber.Ctx) error {
+	api.Get("/notify/send", permit(model.ScopeNotifySend), func(c *fiber.Ctx) error {
 		title := c.Query("title")
 		desc := c.Query("desc")
 
@@ -631,7 +1019,7 @@ func route(fi *fiber.App) {
 		}
 	})
 
-	api.Post("/notify/send/test", func(c *fiber.Ctx) error {
+	api.Post("/notify/send/test", permit(model.ScopeNotifySend), func(c *fiber.Ctx) error {
 		var settings app.SettingsConfiguration
 		if err := c.BodyParser(&settings); err != nil {
 			return c.Status(http.StatusOK).JSON(apiError("Invalid argument. error: " + err.Error()))
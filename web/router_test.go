@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/db"
+	"github.com/bitxeno/atvloadly/internal/manager"
+	"github.com/bitxeno/atvloadly/internal/model"
+	"github.com/bitxeno/atvloadly/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupRouter(t *testing.T) *fiber.App {
+	dir := t.TempDir()
+	app.Config = &app.Configuration{}
+	app.Config.Server.DataDir = dir
+	app.Settings = &app.SettingsConfiguration{}
+
+	if err := db.Open(db.Config{Path: dir, FileName: "test.db"}).AutoMigrate(
+		&model.InstalledApp{},
+		&model.RefreshAttempt{},
+		&model.User{},
+		&model.APIToken{},
+		&model.AuditLog{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	fi := fiber.New()
+	route(fi)
+	return fi
+}
+
+func TestAttemptRoutes(t *testing.T) {
+	fi := setupRouter(t)
+	attempt := model.RefreshAttempt{AppID: 1, Attempt: 1, StartedAt: time.Now(), Outcome: model.AttemptOutcomeFailed, ErrorClass: string(model.FailureDeviceDiskFull)}
+	if err := service.SaveAttempt(&attempt); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(app.AttemptLogDir(app.Config), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manager.AttemptLogPath(attempt.ID), []byte("no space left on device"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := fi.Test(httptest.NewRequest(http.MethodGet, "/api/apps/1/attempts", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Code int                    `json:"code"`
+		Data []model.RefreshAttempt `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != http.StatusOK || len(body.Data) != 1 || body.Data[0].ID != attempt.ID || body.Data[0].ErrorClass != attempt.ErrorClass {
+		t.Fatalf("attempts = %+v, want the saved attempt", body)
+	}
+
+	resp, err = fi.Test(httptest.NewRequest(http.MethodGet, "/api/attempts/1/log", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "no space left on device" {
+		t.Fatalf("log = %d %q, want the attempt log", resp.StatusCode, data)
+	}
+
+	resp, err = fi.Test(httptest.NewRequest(http.MethodGet, "/api/attempts/2/log", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("log of a missing attempt = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestTokenRoutesRequireTokenScopes(t *testing.T) {
+	fi := setupRouter(t)
+	app.Config.Server.Auth.Enabled = true
+
+	admin, err := service.CreateUser("admin", "password", model.UserRoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.CreateUser("agent", "password", model.UserRoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appsToken, _, err := service.CreateAPIToken(*admin, "apps", []string{model.ScopeAppsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokensToken, _, err := service.CreateAPIToken(*admin, "tokens", []string{model.ScopeTokensRead, model.ScopeTokensWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, mcpToken, err := service.CreateAPIToken(*other, "mcp", []string{model.ScopeMCP}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := fi.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	revokePath := fmt.Sprintf("/api/tokens/%d/revoke", mcpToken.ID)
+	for _, r := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/tokens", ""},
+		{http.MethodPost, "/api/tokens", `{"name":"more","scopes":["apps:read"]}`},
+		{http.MethodPost, revokePath, ""},
+	} {
+		if resp := request(r.method, r.path, appsToken, r.body); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s with an apps:read token = %d, want 403", r.method, r.path, resp.StatusCode)
+		}
+	}
+
+	// a token of an admin only manages the tokens of its own user
+	resp := request(http.MethodGet, "/api/tokens", tokensToken, "")
+	var body struct {
+		Code int              `json:"code"`
+		Data []model.APIToken `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != http.StatusOK || len(body.Data) != 2 {
+		t.Fatalf("tokens = %+v, want the 2 tokens of the admin", body)
+	}
+	for _, token := range body.Data {
+		if token.UserID != admin.ID {
+			t.Fatalf("token %s of user %d listed, want only the own ones", token.Name, token.UserID)
+		}
+	}
+	if resp := request(http.MethodPost, revokePath, tokensToken, ""); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("revoking the token of another user = %d, want 403", resp.StatusCode)
+	}
+	if token, _ := service.GetAPIToken(mcpToken.ID); token.IsRevoked() {
+		t.Fatal("the token of another user was revoked")
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+var instance *fiber.App
+
+// stopping is closed on shutdown to stop the background work of the server,
+// redirectServer is the HTTP to HTTPS redirect when enabled.
+var (
+	serverMu       sync.Mutex
+	stopping       = make(chan struct{})
+	redirectServer *http.Server
+)
+
+// open websocket connections, closed on shutdown
+var (
+	wsMu      sync.Mutex
+	wsConns   = make(map[*websocket.Conn]bool)
+	wsWG      sync.WaitGroup
+	wsClosing bool
+)
+
+// trackWebsocket registers the connections of handler so they can be closed
+// on shutdown.
+func trackWebsocket(handler func(*websocket.Conn)) func(*websocket.Conn) {
+	return func(c *websocket.Conn) {
+		wsMu.Lock()
+		if wsClosing {
+			wsMu.Unlock()
+			return
+		}
+		wsConns[c] = true
+		wsWG.Add(1)
+		wsMu.Unlock()
+
+		defer func() {
+			wsMu.Lock()
+			delete(wsConns, c)
+			wsMu.Unlock()
+			wsWG.Done()
+		}()
+		handler(c)
+	}
+}
+
+// Shutdown stops accepting new requests and waits for the active ones until
+// ctx is done. Websockets stay open until CloseWebsockets.
+func Shutdown(ctx context.Context) error {
+	serverMu.Lock()
+	close(stopping)
+	// a server started again gets a new channel
+	stopping = make(chan struct{})
+	redirect := redirectServer
+	redirectServer = nil
+	serverMu.Unlock()
+
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			log.Err(err).Msg("Failed to shutdown HTTP redirect server")
+		}
+	}
+	if instance == nil {
+		return nil
+	}
+	return instance.ShutdownWithContext(ctx)
+}
+
+// CloseWebsockets sends a close frame to every open websocket and waits for
+// their handlers to return until ctx is done.
+func CloseWebsockets(ctx context.Context) {
+	wsMu.Lock()
+	wsClosing = true
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for c := range wsConns {
+		_ = c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = c.Close()
+	}
+	wsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn("Websocket handlers did not stop in time.")
+	}
+}
@@ -1,6 +1,19 @@
 import request from "@/utils/request";
 
 export default {
+  login: (data) => {
+    return request({
+      url: "/api/auth/login",
+      method: "post",
+      data,
+    });
+  },
+  logout: () => {
+    return request({
+      url: "/api/auth/logout",
+      method: "post",
+    });
+  },
   syncLang: (params) => {
     return request({
       url: '/api/lang/sync',
@@ -194,6 +207,13 @@ export default {
     });
   },
 
+  submitTwoFactorCode: (id, data) => {
+    return request({
+      url: `/api/jobs/${id}/2fa`,
+      method: "post",
+      data,
+    });
+  },
   refreshApp: (id) => {
     return request({
       url: `/api/apps/${id}/refresh`,
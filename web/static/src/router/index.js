@@ -1,6 +1,11 @@
 import { createRouter, createWebHashHistory } from "vue-router";
 
 const routes = [
+  {
+    path: "/login",
+    name: "login",
+    component: () => import("@/page/login/index.vue"),
+  },
   {
     path: "/",
     component: () => import("@/page/layout.vue"),
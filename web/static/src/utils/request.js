@@ -51,6 +51,13 @@ service.interceptors.response.use(
   },
   (error) => {
     console.log("err" + error); // for debug
+    // session missing or expired, go to the sign in page
+    if (error.response && error.response.status === 401) {
+      if (!window.location.hash.startsWith("#/login")) {
+        window.location.hash = "#/login";
+      }
+      return Promise.reject(error);
+    }
     toast.error(error.message, { autoClose: 5000 });
     return Promise.reject(error);
   }
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bitxeno/atvloadly/internal/app"
+	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/tty"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	TerminalModeFull       = "full"
+	TerminalModeRestricted = "restricted"
+	TerminalModeDisabled   = "disabled"
+
+	localsTerminalActorKey = "terminal_actor"
+)
+
+type terminal interface {
+	SetCWD(cwd string)
+	SetRecorder(rec *tty.Recorder)
+	Start()
+	Close()
+}
+
+// terminalAllowed rejects terminal sessions when the terminal is disabled and
+// remembers the actor for the session recording.
+func terminalAllowed(c *fiber.Ctx) error {
+	switch app.Config.Server.Terminal.Mode {
+	case TerminalModeFull, TerminalModeRestricted:
+	default:
+		return c.Status(http.StatusForbidden).JSON(apiError("terminal is disabled"))
+	}
+
+	c.Locals(localsTerminalActorKey, fmt.Sprintf("%s from %s", auditActor(c), c.IP()))
+	return c.Next()
+}
+
+func handleTerminal(c *websocket.Conn) {
+	conf := app.Config.Server.Terminal
+
+	var term terminal
+	if conf.Mode == TerminalModeFull {
+		t, err := tty.New(c, "bash")
+		if err != nil {
+			msg := fmt.Sprintf("ERROR: %s", err.Error())
+			_ = c.WriteMessage(websocket.TextMessage, []byte(msg))
+			return
+		}
+		term = t
+	} else {
+		allowlist := conf.Allowlist
+		if len(allowlist) == 0 {
+			allowlist = tty.DefaultAllowlist
+		}
+		term = tty.NewRestricted(c, allowlist)
+	}
+	defer term.Close()
+
+	if conf.Record {
+		actor, _ := c.Locals(localsTerminalActorKey).(string)
+		title := fmt.Sprintf("%s terminal session by %s", conf.Mode, actor)
+		rec, err := tty.NewRecorder(app.RecordingDir(app.Config), title, 120, 60)
+		if err != nil {
+			log.Errorf("Create terminal recording failed: %s", err.Error())
+		} else {
+			term.SetRecorder(rec)
+		}
+	}
+
+	term.SetCWD(app.Config.Server.DataDir)
+	term.Start()
+}
+
+func handleTerminalRecordingList(c *fiber.Ctx) error {
+	recordings, err := tty.ListRecordings(app.RecordingDir(app.Config))
+	if err != nil {
+		return c.Status(http.StatusOK).JSON(apiError(err.Error()))
+	}
+	return c.Status(http.StatusOK).JSON(apiSuccess(recordings))
+}
+
+// handleTerminalRecording returns the asciinema v2 cast file, which can be
+// played with asciinema-player or `asciinema play`.
+func handleTerminalRecording(c *fiber.Ctx) error {
+	path, err := tty.RecordingPath(app.RecordingDir(app.Config), c.Params("name"))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(apiError("recording not found"))
+	}
+	c.Set(fiber.HeaderContentType, "application/x-asciicast")
+	return c.Status(http.StatusOK).SendFile(path, false)
+}
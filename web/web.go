@@ -1,11 +1,19 @@
 package web
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"math"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/bitxeno/atvloadly/internal/app"
 	"github.com/bitxeno/atvloadly/internal/log"
+	"github.com/bitxeno/atvloadly/internal/tlscert"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
@@ -25,10 +33,89 @@ func Run(addr string, port int) error {
 		log.Infof("Web access log file path: %s", app.Config.Log.AccessLog)
 	}
 
+	instance = server
 	route(server)
-	if err := server.Listen(fmt.Sprintf("%s:%d", addr, port)); err != nil {
+
+	listenAddr := fmt.Sprintf("%s:%d", addr, port)
+	if !app.Config.Server.TLS.Enabled {
+		if err := server.Listen(listenAddr); err != nil {
+			log.Error(err.Error())
+			return err
+		}
+		return nil
+	}
+
+	ln, err := listenTLS(listenAddr)
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	if redirectPort := app.Config.Server.TLS.RedirectHTTPPort; redirectPort > 0 {
+		go runHTTPSRedirect(fmt.Sprintf("%s:%d", addr, redirectPort), port)
+	}
+	if err := server.Listener(ln); err != nil {
 		log.Error(err.Error())
 		return err
 	}
 	return nil
 }
+
+func listenTLS(listenAddr string) (net.Listener, error) {
+	conf := app.Config.Server.TLS
+	certFile, keyFile := conf.CertFile, conf.KeyFile
+	selfSigned := certFile == "" || keyFile == ""
+	if selfSigned {
+		var err error
+		certFile, keyFile, err = tlscert.EnsureSelfSigned(app.TLSCertDir(app.Config), conf.Hosts)
+		if err != nil {
+			return nil, fmt.Errorf("create self-signed certificate failed: %w", err)
+		}
+		log.Infof("Using self-signed certificate, import %s into clients to trust it", filepath.Join(app.TLSCertDir(app.Config), tlscert.CAFileName))
+	}
+
+	reloader, err := tlscert.NewReloader(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate failed: %w", err)
+	}
+	if selfSigned {
+		reloader.RenewSelfSigned(app.TLSCertDir(app.Config), conf.Hosts)
+	}
+	serverMu.Lock()
+	stop := stopping
+	serverMu.Unlock()
+	go reloader.Watch(30*time.Second, stop)
+
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("HTTPS enabled, certificate: %s", certFile)
+	return tls.NewListener(ln, &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}), nil
+}
+
+// runHTTPSRedirect serves plain HTTP on addr and redirects every request to
+// the HTTPS port.
+func runHTTPSRedirect(addr string, httpsPort int) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+
+	log.Infof("HTTP to HTTPS redirect listening on %s", addr)
+	srv := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	serverMu.Lock()
+	redirectServer = srv
+	serverMu.Unlock()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("HTTP redirect server stopped: %s", err.Error())
+	}
+}